@@ -5,9 +5,15 @@ import (
 	"github.com/sirupsen/logrus"
 	"net/http"
 	"os"
+	_ "time/tzdata" // restaurant time zones, even without a system zoneinfo
 
 	"rms/database"
+	"rms/geocode"
+	"rms/mailer"
+	"rms/oidc"
+	"rms/revocation"
 	"rms/server"
+	"rms/utils"
 )
 
 func main() {
@@ -16,6 +22,13 @@ func main() {
 		logrus.Warn("No .env file found or failed to load")
 	}
 
+	// Token signing keys
+	keyRing, err := utils.KeyRingFromEnv()
+	if err != nil {
+		logrus.Fatalf("failed to load signing keys: %v", err)
+	}
+	utils.SetKeyRing(keyRing)
+
 	// Connect to database and migrate
 	if dbError := database.ConnectAndMigrate(
 		os.Getenv("DB_HOST"),
@@ -32,6 +45,34 @@ func main() {
 		}
 	}()
 
+	// Outgoing mail
+	m, err := mailer.FromEnv()
+	if err != nil {
+		logrus.Fatalf("failed to configure mailer: %v", err)
+	}
+	mailer.Default = m
+
+	// Access-token revocation
+	store, err := revocation.FromEnv()
+	if err != nil {
+		logrus.Fatalf("failed to configure revocation store: %v", err)
+	}
+	revocation.Default = store
+
+	// Single sign-on, when an identity provider is configured
+	provider, err := oidc.FromEnv()
+	if err != nil {
+		logrus.Fatalf("failed to configure OIDC: %v", err)
+	}
+	oidc.Default = provider
+
+	// Address lookups, when a gazetteer is configured
+	geocoder, err := geocode.FromEnv()
+	if err != nil {
+		logrus.Fatalf("failed to load gazetteer: %v", err)
+	}
+	geocode.Default = geocoder
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -1,23 +1,289 @@
 package dbHelper
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"rms/database"
+	"rms/geo"
+	"rms/geocode"
+	"rms/models"
+	"strconv"
+	"strings"
 )
 
+// distanceKmSQL is the great-circle distance in km between two points given as
+// SQL expressions, the formula every distance in the API is computed with.
+func distanceKmSQL(lat1, lng1, lat2, lng2 string) string {
+	return fmt.Sprintf(`%g * DEGREES(ACOS(LEAST(1.0,
+				COS(RADIANS(%[2]s)) * COS(RADIANS(%[4]s)) *
+				COS(RADIANS(%[3]s - %[5]s)) +
+				SIN(RADIANS(%[2]s)) * SIN(RADIANS(%[4]s))
+			)))`, geo.KmPerDegree, lat1, lng1, lat2, lng2)
+}
+
 func GetDistanceBetweenAddressAndRestaurant(userID, addressID, restaurantID uuid.UUID) (float64, error) {
 	query := `
-		SELECT 
-			111.111 * DEGREES(ACOS(LEAST(1.0, 
-				COS(RADIANS(a.lat)) * COS(RADIANS(r.lat)) *
-				COS(RADIANS(a.lng - r.lng)) +
-				SIN(RADIANS(a.lat)) * SIN(RADIANS(r.lat))
-			)))
+		SELECT ` + distanceKmSQL("a.lat", "a.lng", "r.lat", "r.lng") + `
 		FROM addresses a
-		JOIN restaurants r ON r.id = $3
-		WHERE a.id = $2 AND a.user_id = $1
+		JOIN restaurants r ON r.id = $3 AND r.archived_at IS NULL
+		WHERE a.id = $2 AND a.user_id = $1 AND a.archived_at IS NULL
 	`
 	var distance float64
 	err := database.RMS.QueryRow(query, userID, addressID, restaurantID).Scan(&distance)
 	return distance, err
 }
+
+// GetDistanceMatrix computes, in one query, the distance between every pair of
+// the user's addresses and restaurants. Addresses of other users and archived
+// records come back with AddressOK or RestaurantOK unset.
+func GetDistanceMatrix(userID uuid.UUID, addressIDs, restaurantIDs []uuid.UUID) ([]models.DistanceCell, error) {
+	query := `
+		SELECT req_a.id AS address_id, req_r.id AS restaurant_id,
+		       a.id IS NOT NULL AS address_ok, r.id IS NOT NULL AS restaurant_ok,
+		       ` + distanceKmSQL("a.lat", "a.lng", "r.lat", "r.lng") + ` AS distance_km
+		FROM unnest($2::uuid[]) AS req_a(id)
+		CROSS JOIN unnest($3::uuid[]) AS req_r(id)
+		LEFT JOIN addresses a ON a.id = req_a.id AND a.user_id = $1 AND a.archived_at IS NULL
+		LEFT JOIN restaurants r ON r.id = req_r.id AND r.archived_at IS NULL
+	`
+	cells := make([]models.DistanceCell, 0, len(addressIDs)*len(restaurantIDs))
+	err := database.RMS.Select(&cells, query, userID, uuidArray(addressIDs), uuidArray(restaurantIDs))
+	return cells, err
+}
+
+func uuidArray(ids []uuid.UUID) pq.StringArray {
+	arr := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		arr[i] = id.String()
+	}
+	return arr
+}
+
+// GetUserAddressPoint returns the coordinates of one of the user's active
+// addresses, or found=false if they have no such address.
+func GetUserAddressPoint(userID, addressID uuid.UUID) (lat, lng float64, found bool, err error) {
+	query := `SELECT lat, lng FROM addresses WHERE id = $1 AND user_id = $2 AND archived_at IS NULL`
+	err = database.RMS.QueryRow(query, addressID, userID).Scan(&lat, &lng)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, false, nil
+	}
+	return lat, lng, err == nil, err
+}
+
+// FindNearbyRestaurants returns active restaurants within radiusKm of a point,
+// nearest first. A bounding box on the (lat, lng) index narrows the rows
+// before exact distances are computed. Pages continue from params.Cursor.
+//
+// With deliversOnly, only restaurants that deliver to the point are kept. The
+// radius is checked in SQL and the zone here, reading further batches until
+// the page is full so that filtered rows do not shorten it. With openNow,
+// only restaurants open at the moment are kept.
+func FindNearbyRestaurants(lat, lng, radiusKm float64, deliversOnly, openNow bool, params models.ListParams) (models.Page[models.NearbyRestaurant], error) {
+	page := models.Page[models.NearbyRestaurant]{Items: make([]models.NearbyRestaurant, 0)}
+	box := geo.BoundingBox(lat, lng, radiusKm)
+	q := sqlQuery{}
+
+	inner := []string{"r.archived_at IS NULL"}
+	inner = append(inner, fmt.Sprintf("r.lat BETWEEN %s AND %s", q.arg(box.MinLat), q.arg(box.MaxLat)))
+	if box.WrapsLng {
+		inner = append(inner, fmt.Sprintf("(r.lng >= %s OR r.lng <= %s)", q.arg(box.MinLng), q.arg(box.MaxLng)))
+	} else if box.MinLng > -180 || box.MaxLng < 180 {
+		inner = append(inner, fmt.Sprintf("r.lng BETWEEN %s AND %s", q.arg(box.MinLng), q.arg(box.MaxLng)))
+	}
+	distance := distanceKmSQL(q.arg(lat), q.arg(lng), "r.lat", "r.lng")
+
+	q.cond("distance_km <= %s", radiusKm)
+	if deliversOnly {
+		q.where = append(q.where, "distance_km <= delivery_radius_km")
+	}
+	if openNow {
+		q.where = append(q.where, "is_open_now")
+	}
+
+	// Rows after (afterDistance, afterID) in (distance_km, id) order
+	var afterDistance float64
+	var afterID *uuid.UUID
+	if params.Cursor != "" {
+		cursor, err := decodeCursor(params.Cursor)
+		if err != nil || cursor.Sort != "distance" {
+			return page, fmt.Errorf("%w: bad cursor", ErrInvalidListParams)
+		}
+		if afterDistance, err = strconv.ParseFloat(cursor.Value, 64); err != nil {
+			return page, fmt.Errorf("%w: bad cursor", ErrInvalidListParams)
+		}
+		afterID = &cursor.ID
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = models.DefaultListLimit
+	}
+	for {
+		batchQ := sqlQuery{
+			where: append([]string(nil), q.where...),
+			args:  append([]interface{}(nil), q.args...),
+		}
+		if afterID != nil {
+			batchQ.cond("(distance_km, id) > (%s, %s)", afterDistance, *afterID)
+		}
+		query := `
+			SELECT * FROM (
+				SELECT ` + restaurantSelect + `,
+				       ` + distance + ` AS distance_km
+				FROM restaurants r
+				WHERE ` + strings.Join(inner, " AND ") + `
+			) nearby
+			WHERE ` + strings.Join(batchQ.where, " AND ") + fmt.Sprintf(`
+			ORDER BY distance_km, id
+			LIMIT %d`, limit+1)
+
+		var batch []models.NearbyRestaurant
+		if err := database.RMS.Select(&batch, query, batchQ.args...); err != nil {
+			return page, err
+		}
+		for _, restaurant := range batch {
+			if deliversOnly && !restaurant.DeliversTo(lat, lng, restaurant.DistanceKm) {
+				continue
+			}
+			if len(page.Items) == limit {
+				last := page.Items[limit-1]
+				next := encodeCursor(listCursor{Sort: "distance", Value: formatFieldValue(last.DistanceKm), ID: last.ID})
+				page.NextCursor = &next
+				return page, nil
+			}
+			page.Items = append(page.Items, restaurant)
+		}
+		if len(batch) <= limit {
+			return page, nil
+		}
+		last := batch[len(batch)-1]
+		afterDistance, afterID = last.DistanceKm, &last.ID
+	}
+}
+
+// GetRestaurantFromPoint returns an active restaurant with its distance from
+// a point, or nil if there is no such restaurant.
+func GetRestaurantFromPoint(restaurantID uuid.UUID, lat, lng float64) (*models.NearbyRestaurant, error) {
+	query := `
+		SELECT ` + restaurantSelect + `,
+		       ` + distanceKmSQL("$2", "$3", "r.lat", "r.lng") + ` AS distance_km
+		FROM restaurants r
+		WHERE r.id = $1 AND r.archived_at IS NULL
+	`
+	var restaurant models.NearbyRestaurant
+	err := database.RMS.Get(&restaurant, query, restaurantID, lat, lng)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &restaurant, nil
+}
+
+// firstAddressSQL is true while the user in $2 has no active address, so the
+// first address anyone adds becomes their default.
+const firstAddressSQL = `NOT EXISTS (SELECT 1 FROM addresses WHERE user_id = $2 AND archived_at IS NULL)`
+
+const addressColumns = `id, COALESCE(label, '') AS label, lat, lng, street, city, region, postcode, country, is_default, created_at`
+
+// setAddressSQL sets the structured address columns from the five parameters
+// starting at $first, leaving each unchanged when its parameter is NULL.
+func setAddressSQL(first int) string {
+	return fmt.Sprintf(`street = COALESCE($%d, street), city = COALESCE($%d, city), region = COALESCE($%d, region),
+		    postcode = COALESCE($%d, postcode), country = COALESCE($%d, country)`, first, first+1, first+2, first+3, first+4)
+}
+
+// addressArgs are the parameters for setAddressSQL: all NULL for a nil
+// address, so that it stays as it is.
+func addressArgs(a *geocode.Address) []interface{} {
+	if a == nil {
+		return []interface{}{nil, nil, nil, nil, nil}
+	}
+	return []interface{}{a.Street, a.City, a.Region, a.Postcode, a.Country}
+}
+
+// ListUserAddresses returns the user's active addresses, default first.
+func ListUserAddresses(userID uuid.UUID) ([]models.Address, error) {
+	addresses := make([]models.Address, 0)
+	query := `
+		SELECT ` + addressColumns + `
+		FROM addresses
+		WHERE user_id = $1 AND archived_at IS NULL
+		ORDER BY is_default DESC, created_at, id
+	`
+	err := database.RMS.Select(&addresses, query, userID)
+	return addresses, err
+}
+
+// UpdateUserAddress applies the non-nil fields to one of the user's active
+// addresses and returns it, or nil if they have no such address.
+func UpdateUserAddress(userID, addressID uuid.UUID, req models.UpdateAddressRequest) (*models.Address, error) {
+	query := `
+		UPDATE addresses
+		SET label = COALESCE($3, label),
+		    lat = COALESCE($4, lat),
+		    lng = COALESCE($5, lng),
+		    ` + setAddressSQL(6) + `
+		WHERE id = $1 AND user_id = $2 AND archived_at IS NULL
+		RETURNING ` + addressColumns + `
+	`
+	args := []interface{}{addressID, userID, req.Label, req.Lat, req.Lng}
+	var address models.Address
+	err := database.RMS.QueryRowx(query, append(args, addressArgs(req.Address)...)...).StructScan(&address)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// ArchiveUserAddress archives one of the user's active addresses. An archived
+// address is no longer anyone's default. It reports whether it archived
+// anything.
+func ArchiveUserAddress(userID, addressID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE addresses SET archived_at = CURRENT_TIMESTAMP, is_default = false
+		WHERE id = $1 AND user_id = $2 AND archived_at IS NULL
+	`
+	res, err := database.RMS.Exec(query, addressID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// SetDefaultAddress makes one of the user's active addresses their default in
+// place of any other. It reports whether the address was found.
+func SetDefaultAddress(tx *sqlx.Tx, userID, addressID uuid.UUID) (bool, error) {
+	var found bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM addresses WHERE id = $1 AND user_id = $2 AND archived_at IS NULL)
+	`, addressID, userID).Scan(&found)
+	if err != nil || !found {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE addresses SET is_default = false WHERE user_id = $1 AND is_default AND id <> $2`, userID, addressID); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(`UPDATE addresses SET is_default = true WHERE id = $1`, addressID)
+	return err == nil, err
+}
+
+// GetDefaultAddressID returns the user's default address, or found=false if
+// they have none.
+func GetDefaultAddressID(userID uuid.UUID) (id uuid.UUID, found bool, err error) {
+	query := `SELECT id FROM addresses WHERE user_id = $1 AND is_default AND archived_at IS NULL`
+	err = database.RMS.QueryRow(query, userID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, false, nil
+	}
+	return id, err == nil, err
+}
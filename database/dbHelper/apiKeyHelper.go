@@ -0,0 +1,71 @@
+package dbHelper
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"rms/database"
+	"rms/models"
+	"time"
+)
+
+const apiKeyColumns = `id, name, key_prefix, user_id, roles, scopes, COALESCE(created_by, user_id) AS created_by,
+	created_at, expires_at, last_used_at, revoked_at`
+
+func CreateAPIKey(key *models.APIKey, keyHash string) error {
+	query := `
+		INSERT INTO api_keys (id, name, key_prefix, key_hash, user_id, roles, scopes, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at
+	`
+	return database.RMS.QueryRow(query, key.ID, key.Name, key.KeyPrefix, keyHash, key.UserID,
+		key.Roles, key.Scopes, key.CreatedBy, key.ExpiresAt).Scan(&key.CreatedAt)
+}
+
+// GetActiveAPIKeyByHash finds an unrevoked, unexpired key whose user is still active.
+func GetActiveAPIKeyByHash(keyHash string, now time.Time) (*models.APIKey, error) {
+	var key models.APIKey
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL
+		  AND (k.expires_at IS NULL OR k.expires_at > $2)
+		  AND EXISTS (SELECT 1 FROM users u WHERE u.id = k.user_id AND u.archived_at IS NULL)
+	`
+	err := database.RMS.Get(&key, query, keyHash, now)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// TouchAPIKey records use of a key, at most once a minute to spare the table.
+func TouchAPIKey(id uuid.UUID, now time.Time) error {
+	query := `
+		UPDATE api_keys SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)
+	`
+	_, err := database.RMS.Exec(query, id, now, now.Add(-time.Minute))
+	return err
+}
+
+func ListAPIKeys() ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := database.RMS.Select(&keys, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	return keys, err
+}
+
+func RevokeAPIKey(id uuid.UUID) (bool, error) {
+	res, err := database.RMS.Exec(`UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// CountRoles returns how many of the given role names exist.
+func CountRoles(roleNames []string) (int, error) {
+	var count int
+	err := database.RMS.Get(&count, `SELECT COUNT(*) FROM roles WHERE role_name = ANY($1)`, pq.StringArray(roleNames))
+	return count, err
+}
@@ -0,0 +1,110 @@
+package dbHelper
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"rms/database"
+	"rms/models"
+)
+
+// GetDishOptions returns the variants and modifier groups of an active dish,
+// in display order, or nil if there is no such dish.
+func GetDishOptions(dishID uuid.UUID) (*models.DishOptions, error) {
+	options := models.DishOptions{
+		DishID:         dishID,
+		Variants:       make([]models.DishVariant, 0),
+		ModifierGroups: make([]models.ModifierGroup, 0),
+	}
+	err := database.RMS.Get(&options.Price, `SELECT price FROM dishes WHERE id = $1 AND archived_at IS NULL`, dishID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, name, price, is_default
+		FROM dish_variants
+		WHERE dish_id = $1
+		ORDER BY position
+	`
+	if err := database.RMS.Select(&options.Variants, query, dishID); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT id, name, min_select, max_select
+		FROM modifier_groups
+		WHERE dish_id = $1
+		ORDER BY position
+	`
+	if err := database.RMS.Select(&options.ModifierGroups, query, dishID); err != nil {
+		return nil, err
+	}
+	var choices []models.ModifierOption
+	query = `
+		SELECT o.id, o.group_id, o.name, o.price_delta
+		FROM modifier_options o
+		JOIN modifier_groups g ON g.id = o.group_id
+		WHERE g.dish_id = $1
+		ORDER BY o.position
+	`
+	if err := database.RMS.Select(&choices, query, dishID); err != nil {
+		return nil, err
+	}
+	index := make(map[uuid.UUID]int, len(options.ModifierGroups))
+	for i, g := range options.ModifierGroups {
+		index[g.ID] = i
+		options.ModifierGroups[i].Options = make([]models.ModifierOption, 0)
+	}
+	for _, c := range choices {
+		if i, ok := index[c.GroupID]; ok {
+			options.ModifierGroups[i].Options = append(options.ModifierGroups[i].Options, c)
+		}
+	}
+	return &options, nil
+}
+
+// SetDishOptions replaces a dish's variants and modifier groups.
+func SetDishOptions(tx *sqlx.Tx, dishID uuid.UUID, req models.SetDishOptionsRequest) error {
+	if _, err := tx.Exec(`DELETE FROM dish_variants WHERE dish_id = $1`, dishID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM modifier_groups WHERE dish_id = $1`, dishID); err != nil {
+		return err
+	}
+
+	for i, v := range req.Variants {
+		_, err := tx.Exec(`
+			INSERT INTO dish_variants (dish_id, name, price, is_default, position)
+			VALUES ($1, $2, $3, $4, $5)
+		`, dishID, v.Name, v.Price, v.IsDefault, i)
+		if err != nil {
+			return err
+		}
+	}
+	for i, g := range req.ModifierGroups {
+		var groupID uuid.UUID
+		err := tx.QueryRow(`
+			INSERT INTO modifier_groups (dish_id, name, min_select, max_select, position)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, dishID, g.Name, g.MinSelect, g.MaxSelect, i).Scan(&groupID)
+		if err != nil {
+			return err
+		}
+		for j, o := range g.Options {
+			_, err := tx.Exec(`
+				INSERT INTO modifier_options (group_id, name, price_delta, position)
+				VALUES ($1, $2, $3, $4)
+			`, groupID, o.Name, o.PriceDelta, j)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package dbHelper
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"rms/database"
+	"rms/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidListParams wraps every problem with a caller's sort, filter or cursor.
+var ErrInvalidListParams = errors.New("invalid list parameters")
+
+// fieldKind says how a sort or filter value is parsed and compared.
+type fieldKind int
+
+const (
+	kindText fieldKind = iota
+	kindNumber
+	kindTime
+	kindUUID
+	kindBool
+)
+
+// filterOp is how a filter value is matched against its column.
+type filterOp string
+
+const (
+	opContains filterOp = "contains"
+	opEquals   filterOp = "="
+	opMin      filterOp = ">="
+	opMax      filterOp = "<="
+)
+
+type sortField[T any] struct {
+	expr  string
+	kind  fieldKind
+	value func(T) interface{} // the item's value of expr, for the next cursor
+}
+
+type filterField struct {
+	expr string
+	kind fieldKind
+	op   filterOp
+}
+
+// listSpec describes how one kind of record is listed: the query it comes
+// from, how it can be sorted and what it can be filtered on. Pages are cut
+// with keyset pagination on (sort field, id), so they stay stable while rows
+// are added and each page costs one indexed range scan.
+type listSpec[T any] struct {
+	selectFrom  string // SELECT ... FROM ..., without WHERE
+	idColumn    string
+	id          func(T) uuid.UUID
+	sorts       map[string]sortField[T]
+	defaultSort string
+	filters     map[string]filterField
+}
+
+// listCursor is what next_cursor encodes: where the previous page stopped.
+type listCursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// sqlQuery accumulates WHERE conditions and numbers their placeholders.
+type sqlQuery struct {
+	where []string
+	args  []interface{}
+}
+
+func (q *sqlQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// cond adds a condition in which each %s is a placeholder for one of args.
+func (q *sqlQuery) cond(format string, args ...interface{}) {
+	placeholders := make([]interface{}, len(args))
+	for i, a := range args {
+		placeholders[i] = q.arg(a)
+	}
+	q.where = append(q.where, fmt.Sprintf(format, placeholders...))
+}
+
+func parseFieldValue(kind fieldKind, raw string) (interface{}, error) {
+	switch kind {
+	case kindNumber:
+		return strconv.ParseFloat(raw, 64)
+	case kindTime:
+		if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+			return t.UTC(), nil
+		}
+		t, err := time.Parse("2006-01-02", raw)
+		return t, err
+	case kindUUID:
+		return uuid.Parse(raw)
+	case kindBool:
+		return strconv.ParseBool(raw)
+	}
+	return raw, nil
+}
+
+func formatFieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case uuid.UUID:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func encodeCursor(c listCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(raw, &c)
+	}
+	return c, err
+}
+
+// listPage runs spec's query restricted by base (visibility rules, with $n
+// placeholders for baseArgs) and by params, and returns one page.
+func listPage[T any](spec listSpec[T], params models.ListParams, base []string, baseArgs ...interface{}) (models.Page[T], error) {
+	page := models.Page[T]{Items: make([]T, 0)}
+	query, args, sortName, limit, err := buildListQuery(spec, params, base, baseArgs)
+	if err != nil {
+		return page, err
+	}
+	if err := database.RMS.Select(&page.Items, query, args...); err != nil {
+		return page, err
+	}
+
+	// One extra row tells whether there is a next page
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		last := page.Items[limit-1]
+		next := encodeCursor(listCursor{
+			Sort:  sortName,
+			Desc:  params.Desc,
+			Value: formatFieldValue(spec.sorts[sortName].value(last)),
+			ID:    spec.id(last),
+		})
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// buildListQuery returns the SQL and arguments of one page, fetching a row
+// more than the page size, together with the sort field and page size used.
+func buildListQuery[T any](spec listSpec[T], params models.ListParams, base []string, baseArgs []interface{}) (string, []interface{}, string, int, error) {
+	q := sqlQuery{where: append([]string{}, base...), args: baseArgs}
+
+	sortName := params.Sort
+	if sortName == "" {
+		sortName = spec.defaultSort
+	}
+	sortBy, ok := spec.sorts[sortName]
+	if !ok {
+		return "", nil, "", 0, fmt.Errorf("%w: cannot sort by %q, use one of %s", ErrInvalidListParams, sortName, keys(spec.sorts))
+	}
+
+	// Filters, applied in a fixed order so equal requests build equal SQL
+	names := make([]string, 0, len(params.Filters))
+	for name := range params.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filter, ok := spec.filters[name]
+		if !ok {
+			return "", nil, "", 0, fmt.Errorf("%w: unknown filter %q, use one of %s", ErrInvalidListParams, name, keys(spec.filters))
+		}
+		value, err := parseFieldValue(filter.kind, params.Filters[name])
+		if err != nil {
+			return "", nil, "", 0, fmt.Errorf("%w: bad value for %s", ErrInvalidListParams, name)
+		}
+		if filter.op == opContains {
+			q.cond(filter.expr+" ILIKE '%%' || %s || '%%'", escapeLike(params.Filters[name]))
+		} else {
+			q.cond(filter.expr+" "+string(filter.op)+" %s", value)
+		}
+	}
+
+	// Resume after the last row of the previous page
+	direction, cmp := "ASC", ">"
+	if params.Desc {
+		direction, cmp = "DESC", "<"
+	}
+	if params.Cursor != "" {
+		cursor, err := decodeCursor(params.Cursor)
+		if err != nil || cursor.Sort != sortName || cursor.Desc != params.Desc {
+			return "", nil, "", 0, fmt.Errorf("%w: cursor does not match this sort", ErrInvalidListParams)
+		}
+		value, err := parseFieldValue(sortBy.kind, cursor.Value)
+		if err != nil {
+			return "", nil, "", 0, fmt.Errorf("%w: bad cursor", ErrInvalidListParams)
+		}
+		q.cond("("+sortBy.expr+", "+spec.idColumn+") "+cmp+" (%s, %s)", value, cursor.ID)
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = models.DefaultListLimit
+	}
+	if limit > models.MaxListLimit {
+		limit = models.MaxListLimit
+	}
+
+	query := spec.selectFrom
+	if len(q.where) > 0 {
+		query += "\nWHERE " + strings.Join(q.where, "\n  AND ")
+	}
+	query += fmt.Sprintf("\nORDER BY %s %s, %s %s\nLIMIT %d", sortBy.expr, direction, spec.idColumn, direction, limit+1)
+	return query, q.args, sortName, limit, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func keys[V any](m map[string]V) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
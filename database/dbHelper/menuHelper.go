@@ -0,0 +1,187 @@
+package dbHelper
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"rms/database"
+	"rms/models"
+)
+
+// ErrMenuOrderMismatch means a reorder did not list exactly the sections of
+// the menu, or the active dishes of the section.
+var ErrMenuOrderMismatch = errors.New("order must list every item exactly once")
+
+// FetchMenu returns a restaurant's active dishes grouped by section, both in
+// display order.
+func FetchMenu(restaurantID uuid.UUID) (models.Menu, error) {
+	menu := models.Menu{
+		RestaurantID: restaurantID,
+		Sections:     make([]models.MenuSectionDishes, 0),
+		OtherDishes:  make([]models.Dish, 0),
+	}
+
+	var sections []models.MenuSection
+	query := `
+		SELECT id, restaurant_id, name, description, position, created_at
+		FROM menu_sections
+		WHERE restaurant_id = $1
+		ORDER BY position, name
+	`
+	if err := database.RMS.Select(&sections, query, restaurantID); err != nil {
+		return menu, err
+	}
+	index := make(map[uuid.UUID]int, len(sections))
+	for i, s := range sections {
+		index[s.ID] = i
+		menu.Sections = append(menu.Sections, models.MenuSectionDishes{
+			ID:          s.ID,
+			Name:        s.Name,
+			Description: s.Description,
+			Dishes:      make([]models.Dish, 0),
+		})
+	}
+
+	var dishes []models.Dish
+	query = `
+		SELECT id, dishname, description, price, section_id
+		FROM dishes
+		WHERE restaurant_id = $1 AND archived_at IS NULL
+		ORDER BY position, dishname
+	`
+	if err := database.RMS.Select(&dishes, query, restaurantID); err != nil {
+		return menu, err
+	}
+	for _, d := range dishes {
+		if d.SectionID != nil {
+			if i, ok := index[*d.SectionID]; ok {
+				menu.Sections[i].Dishes = append(menu.Sections[i].Dishes, d)
+				continue
+			}
+		}
+		menu.OtherDishes = append(menu.OtherDishes, d)
+	}
+	return menu, nil
+}
+
+// GetMenuSectionRestaurantID returns the restaurant of a section, or uuid.Nil
+// if there is no such section.
+func GetMenuSectionRestaurantID(sectionID uuid.UUID) (uuid.UUID, error) {
+	var restaurantID uuid.UUID
+	err := database.RMS.Get(&restaurantID, `SELECT restaurant_id FROM menu_sections WHERE id = $1`, sectionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil
+	}
+	return restaurantID, err
+}
+
+// CreateMenuSection adds a section at the end of a restaurant's menu.
+func CreateMenuSection(restaurantID uuid.UUID, name, description string) (models.MenuSection, error) {
+	var section models.MenuSection
+	query := `
+		INSERT INTO menu_sections (restaurant_id, name, description, position)
+		VALUES ($1, $2, $3, COALESCE((SELECT MAX(position) + 1 FROM menu_sections WHERE restaurant_id = $1), 0))
+		RETURNING id, restaurant_id, name, description, position, created_at
+	`
+	err := database.RMS.QueryRowx(query, restaurantID, name, description).StructScan(&section)
+	return section, err
+}
+
+// UpdateMenuSection applies the non-nil fields to a section and returns it,
+// or nil if the restaurant has no such section.
+func UpdateMenuSection(restaurantID, sectionID uuid.UUID, name, description *string) (*models.MenuSection, error) {
+	query := `
+		UPDATE menu_sections
+		SET name = COALESCE($3, name),
+		    description = COALESCE($4, description)
+		WHERE id = $2 AND restaurant_id = $1
+		RETURNING id, restaurant_id, name, description, position, created_at
+	`
+	var section models.MenuSection
+	err := database.RMS.QueryRowx(query, restaurantID, sectionID, name, description).StructScan(&section)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &section, nil
+}
+
+// DeleteMenuSection removes a section, leaving its dishes outside any
+// section, and reports whether it existed.
+func DeleteMenuSection(restaurantID, sectionID uuid.UUID) (bool, error) {
+	res, err := database.RMS.Exec(`DELETE FROM menu_sections WHERE id = $2 AND restaurant_id = $1`, restaurantID, sectionID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReorderMenuSections puts a restaurant's sections in the order of ids, which
+// must list each of them once.
+func ReorderMenuSections(tx *sqlx.Tx, restaurantID uuid.UUID, ids []uuid.UUID) error {
+	var current []uuid.UUID
+	if err := tx.Select(&current, `SELECT id FROM menu_sections WHERE restaurant_id = $1 FOR UPDATE`, restaurantID); err != nil {
+		return err
+	}
+	if !sameIDs(current, ids) {
+		return ErrMenuOrderMismatch
+	}
+	return setPositions(tx, "menu_sections", ids)
+}
+
+// ReorderSectionDishes puts the active dishes of a section in the order of
+// ids, which must list each of them once. It returns sql.ErrNoRows if the
+// restaurant has no such section.
+func ReorderSectionDishes(tx *sqlx.Tx, restaurantID, sectionID uuid.UUID, ids []uuid.UUID) error {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM menu_sections WHERE id = $2 AND restaurant_id = $1)`
+	if err := tx.Get(&exists, query, restaurantID, sectionID); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	var current []uuid.UUID
+	query = `SELECT id FROM dishes WHERE section_id = $1 AND archived_at IS NULL FOR UPDATE`
+	if err := tx.Select(&current, query, sectionID); err != nil {
+		return err
+	}
+	if !sameIDs(current, ids) {
+		return ErrMenuOrderMismatch
+	}
+	return setPositions(tx, "dishes", ids)
+}
+
+// setPositions numbers the rows of table from 0 in the order of ids.
+func setPositions(tx *sqlx.Tx, table string, ids []uuid.UUID) error {
+	query := `
+		UPDATE ` + table + ` t SET position = o.ord - 1
+		FROM unnest($1::uuid[]) WITH ORDINALITY AS o(id, ord)
+		WHERE t.id = o.id
+	`
+	_, err := tx.Exec(query, uuidArray(ids))
+	return err
+}
+
+// sameIDs reports whether ids lists exactly the members of current.
+func sameIDs(current, ids []uuid.UUID) bool {
+	if len(current) != len(ids) {
+		return false
+	}
+	seen := make(map[uuid.UUID]bool, len(current))
+	for _, id := range current {
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package dbHelper
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"rms/database"
+	"rms/models"
+)
+
+// GetUserMFA returns the user's TOTP enrollment, or nil if there is none.
+func GetUserMFA(userID uuid.UUID) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	query := `SELECT user_id, secret, last_used_step, enabled_at, created_at FROM user_mfa WHERE user_id = $1`
+	err := database.RMS.Get(&mfa, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+func IsMFAEnabled(userID uuid.UUID) (bool, error) {
+	var enabled bool
+	query := `SELECT EXISTS (SELECT 1 FROM user_mfa WHERE user_id = $1 AND enabled_at IS NOT NULL)`
+	err := database.RMS.Get(&enabled, query, userID)
+	return enabled, err
+}
+
+// StartMFAEnrollment stores a new unconfirmed secret, replacing any earlier
+// unconfirmed one. It reports false if 2FA is already enabled.
+func StartMFAEnrollment(userID uuid.UUID, secret string) (bool, error) {
+	query := `
+		INSERT INTO user_mfa (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+		WHERE user_mfa.enabled_at IS NULL
+	`
+	res, err := database.RMS.Exec(query, userID, secret)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func EnableMFA(db sqlx.Ext, userID uuid.UUID, step int64) error {
+	_, err := db.Exec(`UPDATE user_mfa SET enabled_at = CURRENT_TIMESTAMP, last_used_step = $2 WHERE user_id = $1`, userID, step)
+	return err
+}
+
+// UseMFAStep records a successfully used time step and reports false if that
+// step, or a later one, was already used, so a code cannot be replayed.
+func UseMFAStep(userID uuid.UUID, step int64) (bool, error) {
+	res, err := database.RMS.Exec(`UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func ReplaceRecoveryCodes(db sqlx.Ext, userID uuid.UUID, codeHashes []string) error {
+	if _, err := db.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := db.Exec(`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode burns an unused recovery code and reports whether it was valid.
+func UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	res, err := database.RMS.Exec(query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func DisableMFA(db sqlx.Ext, userID uuid.UUID) error {
+	if _, err := db.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM user_mfa WHERE user_id = $1`, userID)
+	return err
+}
@@ -0,0 +1,68 @@
+package dbHelper
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"rms/database"
+	"rms/models"
+)
+
+func CreateOIDCLoginState(state *models.OIDCLoginState) error {
+	query := `
+		INSERT INTO oidc_login_states (state, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := database.RMS.Exec(query, state.State, state.Nonce, state.CodeVerifier, state.ExpiresAt)
+	return err
+}
+
+// ConsumeOIDCLoginState deletes and returns an unexpired login state, so each
+// callback can be completed only once. It returns nil for an unknown state.
+func ConsumeOIDCLoginState(state string) (*models.OIDCLoginState, error) {
+	var login models.OIDCLoginState
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state = $1 AND expires_at > CURRENT_TIMESTAMP
+		RETURNING state, nonce, code_verifier, expires_at
+	`
+	err := database.RMS.Get(&login, query, state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Sweep abandoned logins while we are here
+	if _, err := database.RMS.Exec(`DELETE FROM oidc_login_states WHERE expires_at <= CURRENT_TIMESTAMP`); err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// GetIdentityUserID returns the active user linked to subject at issuer, or uuid.Nil.
+func GetIdentityUserID(issuer, subject string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	query := `
+		SELECT i.user_id
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.issuer = $1 AND i.subject = $2 AND u.archived_at IS NULL
+	`
+	err := database.RMS.Get(&userID, query, issuer, subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil
+	}
+	return userID, err
+}
+
+func LinkUserIdentity(db sqlx.Ext, identity models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (issuer, subject, user_id, email)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := db.Exec(query, identity.Issuer, identity.Subject, identity.UserID, identity.Email)
+	return err
+}
@@ -0,0 +1,169 @@
+package dbHelper
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"rms/database"
+	"rms/models"
+	"rms/schedule"
+	"time"
+)
+
+// GetRestaurantSchedules loads the schedules of the given active restaurants,
+// with closures that have not ended by from. Restaurants that do not exist
+// or are archived are missing from the map.
+func GetRestaurantSchedules(ids []uuid.UUID, from time.Time) (map[uuid.UUID]schedule.Schedule, error) {
+	schedules := make(map[uuid.UUID]schedule.Schedule, len(ids))
+	if len(ids) == 0 {
+		return schedules, nil
+	}
+
+	var restaurants []struct {
+		ID          uuid.UUID  `db:"id"`
+		TimeZone    string     `db:"time_zone"`
+		IsPaused    bool       `db:"is_paused"`
+		PausedUntil *time.Time `db:"paused_until"`
+	}
+	query := `
+		SELECT id, time_zone, is_paused, paused_until
+		FROM restaurants
+		WHERE id = ANY($1::uuid[]) AND archived_at IS NULL
+	`
+	if err := database.RMS.Select(&restaurants, query, uuidArray(ids)); err != nil {
+		return nil, err
+	}
+	for _, r := range restaurants {
+		loc, err := time.LoadLocation(r.TimeZone)
+		if err != nil {
+			logrus.Errorf("restaurant %s has unknown time zone %q, using UTC", r.ID, r.TimeZone)
+			loc = time.UTC
+		}
+		schedules[r.ID] = schedule.Schedule{Location: loc, Paused: r.IsPaused, PausedUntil: r.PausedUntil}
+	}
+
+	var hours []struct {
+		RestaurantID uuid.UUID `db:"restaurant_id"`
+		Weekday      int       `db:"weekday"`
+		Opens        int       `db:"opens_minute"`
+		Closes       int       `db:"closes_minute"`
+	}
+	query = `
+		SELECT restaurant_id, weekday, opens_minute, closes_minute
+		FROM restaurant_hours
+		WHERE restaurant_id = ANY($1::uuid[])
+		ORDER BY weekday, opens_minute
+	`
+	if err := database.RMS.Select(&hours, query, uuidArray(ids)); err != nil {
+		return nil, err
+	}
+	for _, h := range hours {
+		if s, ok := schedules[h.RestaurantID]; ok {
+			s.Intervals = append(s.Intervals, schedule.Interval{Weekday: time.Weekday(h.Weekday), Opens: h.Opens, Closes: h.Closes})
+			schedules[h.RestaurantID] = s
+		}
+	}
+
+	var closures []struct {
+		RestaurantID uuid.UUID `db:"restaurant_id"`
+		StartsAt     time.Time `db:"starts_at"`
+		EndsAt       time.Time `db:"ends_at"`
+	}
+	query = `
+		SELECT restaurant_id, starts_at, ends_at
+		FROM restaurant_closures
+		WHERE restaurant_id = ANY($1::uuid[]) AND ends_at > $2
+	`
+	if err := database.RMS.Select(&closures, query, uuidArray(ids), from); err != nil {
+		return nil, err
+	}
+	for _, c := range closures {
+		if s, ok := schedules[c.RestaurantID]; ok {
+			s.Closures = append(s.Closures, schedule.Closure{StartsAt: c.StartsAt, EndsAt: c.EndsAt})
+			schedules[c.RestaurantID] = s
+		}
+	}
+	return schedules, nil
+}
+
+// ErrUnknownTimeZone is returned for a time zone the database does not know.
+var ErrUnknownTimeZone = errors.New("unknown time zone")
+
+// SetOpeningHours replaces a restaurant's weekly hours, and its time zone
+// when timeZone is not nil.
+func SetOpeningHours(tx *sqlx.Tx, restaurantID uuid.UUID, timeZone *string, intervals []schedule.Interval) error {
+	if timeZone != nil {
+		// restaurant_is_open converts with it, so Postgres must know it too
+		var known bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = $1)`, *timeZone).Scan(&known); err != nil {
+			return err
+		}
+		if !known {
+			return ErrUnknownTimeZone
+		}
+		if _, err := tx.Exec(`UPDATE restaurants SET time_zone = $2 WHERE id = $1`, restaurantID, *timeZone); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM restaurant_hours WHERE restaurant_id = $1`, restaurantID); err != nil {
+		return err
+	}
+	for _, iv := range intervals {
+		_, err := tx.Exec(`
+			INSERT INTO restaurant_hours (restaurant_id, weekday, opens_minute, closes_minute)
+			VALUES ($1, $2, $3, $4)
+		`, restaurantID, int(iv.Weekday), iv.Opens, iv.Closes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListRestaurantClosures returns the closures that have not ended by from,
+// soonest first.
+func ListRestaurantClosures(restaurantID uuid.UUID, from time.Time) ([]models.Closure, error) {
+	closures := make([]models.Closure, 0)
+	query := `
+		SELECT id, restaurant_id, starts_at, ends_at, reason, created_by, created_at
+		FROM restaurant_closures
+		WHERE restaurant_id = $1 AND ends_at > $2
+		ORDER BY starts_at, id
+	`
+	err := database.RMS.Select(&closures, query, restaurantID, from)
+	return closures, err
+}
+
+func AddRestaurantClosure(restaurantID uuid.UUID, startsAt, endsAt time.Time, reason string, createdBy uuid.UUID) (models.Closure, error) {
+	var closure models.Closure
+	query := `
+		INSERT INTO restaurant_closures (restaurant_id, starts_at, ends_at, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, restaurant_id, starts_at, ends_at, reason, created_by, created_at
+	`
+	err := database.RMS.QueryRowx(query, restaurantID, startsAt, endsAt, reason, createdBy).StructScan(&closure)
+	return closure, err
+}
+
+// RemoveRestaurantClosure deletes a closure and reports whether it existed.
+func RemoveRestaurantClosure(restaurantID, closureID uuid.UUID) (bool, error) {
+	res, err := database.RMS.Exec(`DELETE FROM restaurant_closures WHERE id = $1 AND restaurant_id = $2`, closureID, restaurantID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// SetRestaurantPause flips the paused/busy switch of an active restaurant and
+// reports whether it exists.
+func SetRestaurantPause(restaurantID uuid.UUID, paused bool, until *time.Time) (bool, error) {
+	query := `UPDATE restaurants SET is_paused = $2, paused_until = $3 WHERE id = $1 AND archived_at IS NULL`
+	res, err := database.RMS.Exec(query, restaurantID, paused, until)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
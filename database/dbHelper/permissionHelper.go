@@ -0,0 +1,125 @@
+package dbHelper
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"rms/database"
+	"rms/models"
+)
+
+// GetRolePermissions returns every role's permission names, keyed by role name.
+func GetRolePermissions() (map[string][]string, error) {
+	var rows []struct {
+		Role       string `db:"role_name"`
+		Permission string `db:"name"`
+	}
+	query := `
+		SELECT r.role_name, p.name
+		FROM role_permissions rp
+		JOIN roles r ON r.id = rp.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+	`
+	if err := database.RMS.Select(&rows, query); err != nil {
+		return nil, err
+	}
+
+	perms := make(map[string][]string)
+	for _, row := range rows {
+		perms[row.Role] = append(perms[row.Role], row.Permission)
+	}
+	return perms, nil
+}
+
+func ListPermissions() ([]models.Permission, error) {
+	permissions := make([]models.Permission, 0)
+	err := database.RMS.Select(&permissions, `SELECT id, name, description FROM permissions ORDER BY name`)
+	return permissions, err
+}
+
+// CountPermissions returns how many of the given permission names exist.
+func CountPermissions(names []string) (int, error) {
+	var count int
+	err := database.RMS.Get(&count, `SELECT COUNT(*) FROM permissions WHERE name = ANY($1)`, pq.StringArray(names))
+	return count, err
+}
+
+const roleColumns = `r.id, r.role_name, r.is_system,
+	ARRAY(SELECT p.name FROM role_permissions rp JOIN permissions p ON p.id = rp.permission_id
+	      WHERE rp.role_id = r.id ORDER BY p.name) AS permissions`
+
+func ListRoles() ([]models.Role, error) {
+	roles := make([]models.Role, 0)
+	err := database.RMS.Select(&roles, `SELECT `+roleColumns+` FROM roles r ORDER BY r.role_name`)
+	return roles, err
+}
+
+// GetRoleByName returns the role, or nil if there is none.
+func GetRoleByName(name string) (*models.Role, error) {
+	var role models.Role
+	err := database.RMS.Get(&role, `SELECT `+roleColumns+` FROM roles r WHERE r.role_name = $1`, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func CreateRole(db sqlx.Ext, id uuid.UUID, name string) error {
+	_, err := db.Exec(`INSERT INTO roles (id, role_name) VALUES ($1, $2)`, id, name)
+	return err
+}
+
+// DeleteRole removes a custom role that nobody holds and reports whether it did.
+func DeleteRole(roleID uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM roles r
+		WHERE r.id = $1 AND NOT r.is_system
+		  AND NOT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.role_id = r.id)
+		  AND NOT EXISTS (SELECT 1 FROM api_keys k WHERE r.role_name = ANY(k.roles) AND k.revoked_at IS NULL)
+	`
+	res, err := database.RMS.Exec(query, roleID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func GrantPermissions(db sqlx.Ext, roleID uuid.UUID, names []string) error {
+	query := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, p.id FROM permissions p WHERE p.name = ANY($2)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := db.Exec(query, roleID, pq.StringArray(names))
+	return err
+}
+
+// RevokePermission takes a permission away from a role and reports whether it had it.
+func RevokePermission(roleID uuid.UUID, name string) (bool, error) {
+	query := `
+		DELETE FROM role_permissions
+		WHERE role_id = $1 AND permission_id = (SELECT id FROM permissions WHERE name = $2)
+	`
+	res, err := database.RMS.Exec(query, roleID, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RemoveRoleFromUser reports whether the user held the role.
+func RemoveRoleFromUser(db sqlx.Ext, userID, roleID uuid.UUID) (bool, error) {
+	res, err := db.Exec(`DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`, userID, roleID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,95 @@
+package dbHelper
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"rms/database"
+	"rms/models"
+)
+
+const memberColumns = `m.restaurant_id, m.user_id, u.username, u.email, m.role, m.invited_by, m.created_at`
+
+func AddRestaurantMember(db sqlx.Ext, restaurantID, userID uuid.UUID, role string, invitedBy *uuid.UUID) error {
+	query := `
+		INSERT INTO restaurant_members (restaurant_id, user_id, role, invited_by)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := db.Exec(query, restaurantID, userID, role, invitedBy)
+	return err
+}
+
+// GetMemberRole returns the user's staff role at a restaurant that is active,
+// or archived if archived is set, or "" if they are not a member.
+func GetMemberRole(restaurantID, userID uuid.UUID, archived bool) (string, error) {
+	var role string
+	query := `
+		SELECT m.role
+		FROM restaurant_members m
+		JOIN restaurants r ON r.id = m.restaurant_id
+		WHERE m.restaurant_id = $1 AND m.user_id = $2 AND (r.archived_at IS NOT NULL) = $3
+	`
+	err := database.RMS.Get(&role, query, restaurantID, userID, archived)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+func ListRestaurantMembers(restaurantID uuid.UUID) ([]models.RestaurantMember, error) {
+	members := make([]models.RestaurantMember, 0)
+	query := `
+		SELECT ` + memberColumns + `
+		FROM restaurant_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.restaurant_id = $1
+		ORDER BY m.created_at
+	`
+	err := database.RMS.Select(&members, query, restaurantID)
+	return members, err
+}
+
+// GetRestaurantMember returns the membership, or nil if there is none.
+func GetRestaurantMember(restaurantID, userID uuid.UUID) (*models.RestaurantMember, error) {
+	var member models.RestaurantMember
+	query := `
+		SELECT ` + memberColumns + `
+		FROM restaurant_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.restaurant_id = $1 AND m.user_id = $2
+	`
+	err := database.RMS.Get(&member, query, restaurantID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// LockRestaurantMembers serialises membership changes of one restaurant, so
+// checks like "is this the last owner" hold until the transaction commits.
+func LockRestaurantMembers(tx *sqlx.Tx, restaurantID uuid.UUID) error {
+	_, err := tx.Exec(`SELECT id FROM restaurants WHERE id = $1 FOR UPDATE`, restaurantID)
+	return err
+}
+
+func CountRestaurantOwners(db sqlx.Ext, restaurantID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM restaurant_members WHERE restaurant_id = $1 AND role = $2`
+	err := sqlx.Get(db, &count, query, restaurantID, models.MemberOwner)
+	return count, err
+}
+
+func UpdateMemberRole(db sqlx.Ext, restaurantID, userID uuid.UUID, role string) error {
+	_, err := db.Exec(`UPDATE restaurant_members SET role = $3 WHERE restaurant_id = $1 AND user_id = $2`,
+		restaurantID, userID, role)
+	return err
+}
+
+func RemoveRestaurantMember(db sqlx.Ext, restaurantID, userID uuid.UUID) error {
+	_, err := db.Exec(`DELETE FROM restaurant_members WHERE restaurant_id = $1 AND user_id = $2`, restaurantID, userID)
+	return err
+}
@@ -2,20 +2,26 @@ package dbHelper
 
 import (
 	"database/sql"
+	"errors"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"rms/database"
 	"rms/models"
+	"time"
 )
 
-func CreateRestaurant(name string, lat, lng float64, createdBy uuid.UUID) (uuid.UUID, error) {
+func CreateRestaurant(db sqlx.Ext, req models.CreateRestaurantRequest, createdBy uuid.UUID) (uuid.UUID, error) {
 	query := `
-		INSERT INTO restaurants (id, restaurantname, lat, lng, created_by)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO restaurants (id, restaurantname, lat, lng, created_by, delivery_radius_km, delivery_zone,
+		                         street, city, region, postcode, country)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		RETURNING id
 	`
 
 	id := uuid.New()
-	err := database.RMS.QueryRow(query, id, name, lat, lng, createdBy).Scan(&id)
+	a := req.Address
+	err := db.QueryRowx(query, id, req.RestaurantName, req.Lat, req.Lng, createdBy, req.DeliveryRadiusKm, req.DeliveryZone,
+		a.Street, a.City, a.Region, a.Postcode, a.Country).Scan(&id)
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -29,123 +35,253 @@ func DoesRestaurantExist(id uuid.UUID) (bool, error) {
 	return exists, err
 }
 
-func CreateDish(dishName string, restaurantID, createdBy uuid.UUID, price float64) (uuid.UUID, error) {
-	var dishID uuid.UUID
-	query := `
-		INSERT INTO dishes (id, dishname, restaurant_id, created_by, price)
-		VALUES (gen_random_uuid(), $1, $2, $3, $4)
-		RETURNING id`
-	err := database.RMS.QueryRow(query, dishName, restaurantID, createdBy, price).Scan(&dishID)
-	return dishID, err
+// IsRestaurantArchived reports whether the restaurant exists and is archived.
+func IsRestaurantArchived(id uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM restaurants WHERE id = $1 AND archived_at IS NOT NULL)`
+	var archived bool
+	err := database.RMS.QueryRow(query, id).Scan(&archived)
+	return archived, err
 }
 
-func FetchAllRestaurants() ([]models.Restaurant, error) {
+// UpdateRestaurant applies the non-nil fields to an active restaurant and
+// returns it, or nil if there is no such restaurant.
+func UpdateRestaurant(id uuid.UUID, req models.UpdateRestaurantRequest) (*models.Restaurant, error) {
 	query := `
-		SELECT id, restaurantname, created_by, lat, lng
-		FROM restaurants
-		WHERE archived_at IS NULL
+		UPDATE restaurants
+		SET restaurantname = COALESCE($2, restaurantname),
+		    lat = COALESCE($3, lat),
+		    lng = COALESCE($4, lng),
+		    delivery_radius_km = COALESCE($5, delivery_radius_km),
+		    delivery_zone = CASE WHEN $7 THEN NULL ELSE COALESCE($6, delivery_zone) END,
+		    ` + setAddressSQL(8) + `
+		WHERE id = $1 AND archived_at IS NULL
+		RETURNING ` + restaurantColumns + `
 	`
-
-	rows, err := database.RMS.Query(query)
+	args := []interface{}{id, req.RestaurantName, req.Lat, req.Lng,
+		req.DeliveryRadiusKm, req.DeliveryZone, req.ClearDeliveryZone}
+	var r models.Restaurant
+	err := database.RMS.QueryRowx(query, append(args, addressArgs(req.Address)...)...).StructScan(&r)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return &r, nil
+}
 
-	var restaurants []models.Restaurant
-	for rows.Next() {
-		var r models.Restaurant
-		if err := rows.Scan(&r.ID, &r.Name, &r.CreatedBy, &r.Lat, &r.Lng); err != nil {
-			return nil, err
-		}
-		restaurants = append(restaurants, r)
+// ArchiveRestaurant archives an active restaurant together with its active
+// dishes, stamping both with the same time so a restore can tell them apart
+// from dishes archived on their own. It reports whether it archived anything.
+func ArchiveRestaurant(db sqlx.Ext, id uuid.UUID, at time.Time) (bool, error) {
+	res, err := db.Exec(`UPDATE restaurants SET archived_at = $2 WHERE id = $1 AND archived_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
 	}
-	return restaurants, nil
+	_, err = db.Exec(`UPDATE dishes SET archived_at = $2 WHERE restaurant_id = $1 AND archived_at IS NULL`, id, at)
+	return err == nil, err
 }
 
-func FetchDishesByRestaurant(restaurantID uuid.UUID) ([]models.Dish, error) {
-	query := `
-        SELECT id, dishname, price
-        FROM dishes
-        WHERE restaurant_id = $1 AND archived_at IS NULL
-    `
-	rows, err := database.RMS.Query(query, restaurantID)
+// RestoreRestaurant brings back an archived restaurant and the dishes that
+// were archived with it, and reports whether it restored anything.
+func RestoreRestaurant(tx *sqlx.Tx, id uuid.UUID) (bool, error) {
+	var archivedAt time.Time
+	err := tx.Get(&archivedAt, `SELECT archived_at FROM restaurants WHERE id = $1 AND archived_at IS NOT NULL FOR UPDATE`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer rows.Close()
-
-	var dishes []models.Dish
-	for rows.Next() {
-		var dish models.Dish
-		err := rows.Scan(&dish.ID, &dish.DishName, &dish.Price)
-		if err != nil {
-			return nil, err
-		}
-		dishes = append(dishes, dish)
+	if _, err := tx.Exec(`UPDATE restaurants SET archived_at = NULL WHERE id = $1`, id); err != nil {
+		return false, err
 	}
+	_, err = tx.Exec(`UPDATE dishes SET archived_at = NULL WHERE restaurant_id = $1 AND archived_at = $2`, id, archivedAt)
+	return err == nil, err
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+// PurgeRestaurant permanently deletes an archived restaurant, its dishes and
+// its staff, and reports whether it deleted anything.
+func PurgeRestaurant(db sqlx.Ext, id uuid.UUID) (bool, error) {
+	if _, err := db.Exec(`DELETE FROM dishes WHERE restaurant_id = $1 AND EXISTS (
+		SELECT 1 FROM restaurants WHERE id = $1 AND archived_at IS NOT NULL)`, id); err != nil {
+		return false, err
 	}
+	res, err := db.Exec(`DELETE FROM restaurants WHERE id = $1 AND archived_at IS NOT NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
 
-	return dishes, nil
+// CreateDish adds a dish at the end of its section, or outside any section
+// when sectionID is nil.
+func CreateDish(dishName, description string, restaurantID, createdBy uuid.UUID, price float64, sectionID *uuid.UUID) (uuid.UUID, error) {
+	var dishID uuid.UUID
+	query := `
+		INSERT INTO dishes (id, dishname, restaurant_id, created_by, price, description, section_id, position)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6,
+		        COALESCE((SELECT MAX(position) + 1 FROM dishes WHERE section_id = $6), 0))
+		RETURNING id`
+	err := database.RMS.QueryRow(query, dishName, restaurantID, createdBy, price, description, sectionID).Scan(&dishID)
+	return dishID, err
 }
 
-// dbHelper/restaurant.go
+// restaurantColumns are the columns of models.Restaurant, for RETURNING
+// lists, and restaurantSelect the same on restaurants aliased as r.
+const (
+	restaurantColumns = `id, restaurantname, created_by, created_at, lat, lng, archived_at,
+		street, city, region, postcode, country, delivery_radius_km, delivery_zone,
+		time_zone, is_paused, paused_until, restaurant_is_open(id, now()) AS is_open_now`
+	restaurantSelect = `r.id, r.restaurantname, r.created_by, r.created_at, r.lat, r.lng, r.archived_at,
+		r.street, r.city, r.region, r.postcode, r.country, r.delivery_radius_km, r.delivery_zone,
+		r.time_zone, r.is_paused, r.paused_until, restaurant_is_open(r.id, now()) AS is_open_now`
+)
 
-func GetRestaurantsVisibleTo(userID uuid.UUID, isAdmin bool) ([]models.Restaurant, error) {
-	var rows *sql.Rows
-	var err error
+var restaurantList = listSpec[models.Restaurant]{
+	selectFrom: `SELECT ` + restaurantSelect + ` FROM restaurants r`,
+	idColumn:   "r.id",
+	id:         func(r models.Restaurant) uuid.UUID { return r.ID },
+	sorts: map[string]sortField[models.Restaurant]{
+		"name":       {"r.restaurantname", kindText, func(r models.Restaurant) interface{} { return r.Name }},
+		"created_at": {"r.created_at", kindTime, func(r models.Restaurant) interface{} { return r.CreatedAt }},
+	},
+	defaultSort: "created_at",
+	filters: map[string]filterField{
+		"name":           {"r.restaurantname", kindText, opContains},
+		"created_by":     {"r.created_by", kindUUID, opEquals},
+		"created_after":  {"r.created_at", kindTime, opMin},
+		"created_before": {"r.created_at", kindTime, opMax},
+		"open_now":       {"restaurant_is_open(r.id, now())", kindBool, opEquals},
+	},
+}
 
-	if isAdmin {
-		rows, err = database.RMS.Query(`SELECT id, restaurantname, lat, lng, created_by FROM restaurants`)
-	} else {
-		rows, err = database.RMS.Query(`SELECT id, restaurantname, lat, lng, created_by FROM restaurants WHERE created_by = $1`, userID)
-	}
+func FetchAllRestaurants(params models.ListParams) (models.Page[models.Restaurant], error) {
+	return listPage(restaurantList, params, []string{"r.archived_at IS NULL"})
+}
 
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// dbHelper/restaurant.go
 
-	var restaurants []models.Restaurant
-	for rows.Next() {
-		var r models.Restaurant
-		if err := rows.Scan(&r.ID, &r.Name, &r.Lat, &r.Lng, &r.CreatedBy); err != nil {
-			return nil, err
-		}
-		restaurants = append(restaurants, r)
+func GetRestaurantsVisibleTo(userID uuid.UUID, seeAll bool, params models.ListParams) (models.Page[models.Restaurant], error) {
+	if seeAll {
+		return listPage(restaurantList, params, nil)
 	}
-
-	return restaurants, nil
+	return listPage(restaurantList, params,
+		[]string{"r.id IN (SELECT restaurant_id FROM restaurant_members WHERE user_id = $1)"}, userID)
 }
 
 // dbHelper/dishes.go
 
-func GetDishesVisibleTo(userID uuid.UUID, isAdmin bool) ([]models.Dishes, error) {
-	var rows *sql.Rows
-	var err error
+// dishSelect are the columns of models.Dishes on dishes aliased as d.
+const dishSelect = `d.id, d.dishname, d.restaurant_id, d.created_by, d.price, d.description,
+	d.section_id, d.position, d.created_at, d.archived_at`
+
+var dishList = listSpec[models.Dishes]{
+	selectFrom: `SELECT ` + dishSelect + ` FROM dishes d`,
+	idColumn:   "d.id",
+	id:         func(d models.Dishes) uuid.UUID { return d.ID },
+	sorts: map[string]sortField[models.Dishes]{
+		"name":       {"d.dishname", kindText, func(d models.Dishes) interface{} { return d.Name }},
+		"price":      {"d.price", kindNumber, func(d models.Dishes) interface{} { return d.Price }},
+		"created_at": {"d.created_at", kindTime, func(d models.Dishes) interface{} { return d.CreatedAt }},
+	},
+	defaultSort: "created_at",
+	filters: map[string]filterField{
+		"name":           {"d.dishname", kindText, opContains},
+		"price_min":      {"d.price", kindNumber, opMin},
+		"price_max":      {"d.price", kindNumber, opMax},
+		"restaurant_id":  {"d.restaurant_id", kindUUID, opEquals},
+		"created_by":     {"d.created_by", kindUUID, opEquals},
+		"created_after":  {"d.created_at", kindTime, opMin},
+		"created_before": {"d.created_at", kindTime, opMax},
+	},
+}
+
+func GetDishesVisibleTo(userID uuid.UUID, seeAll, includeArchived bool, params models.ListParams) (models.Page[models.Dishes], error) {
+	var where []string
+	var args []interface{}
+	if !seeAll {
+		args = append(args, userID)
+		where = append(where, "d.restaurant_id IN (SELECT restaurant_id FROM restaurant_members WHERE user_id = $1)")
+	}
+	if !includeArchived {
+		where = append(where, "d.archived_at IS NULL")
+	}
+	return listPage(dishList, params, where, args...)
+}
 
-	if isAdmin {
-		rows, err = database.RMS.Query(`SELECT id, dishname, restaurant_id, created_by, price FROM dishes`)
-	} else {
-		rows, err = database.RMS.Query(`SELECT id, dishname, restaurant_id, created_by, price FROM dishes WHERE created_by = $1`, userID)
+// GetDishRestaurantID returns the restaurant of a dish that is active, or
+// archived if archived is set, or uuid.Nil if there is no such dish.
+func GetDishRestaurantID(dishID uuid.UUID, archived bool) (uuid.UUID, error) {
+	var restaurantID uuid.UUID
+	query := `SELECT restaurant_id FROM dishes WHERE id = $1 AND (archived_at IS NOT NULL) = $2`
+	err := database.RMS.Get(&restaurantID, query, dishID, archived)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil
 	}
+	return restaurantID, err
+}
 
+// UpdateDish applies the non-nil fields to an active dish and returns it, or
+// nil if there is no such dish. A dish moved to another restaurant leaves its
+// section unless sectionID is given, and one moved to another section goes
+// to its end.
+func UpdateDish(id uuid.UUID, name, description *string, price *float64, restaurantID, sectionID *uuid.UUID, clearSection bool) (*models.Dishes, error) {
+	query := `
+		UPDATE dishes d
+		SET dishname = COALESCE($2, d.dishname),
+		    description = COALESCE($3, d.description),
+		    price = COALESCE($4, d.price),
+		    restaurant_id = COALESCE($5, d.restaurant_id),
+		    section_id = CASE
+		        WHEN $7 THEN NULL
+		        WHEN $6::uuid IS NOT NULL THEN $6
+		        WHEN $5::uuid IS NOT NULL AND $5 <> d.restaurant_id THEN NULL
+		        ELSE d.section_id
+		    END,
+		    position = CASE
+		        WHEN $6::uuid IS NOT NULL AND $6 IS DISTINCT FROM d.section_id
+		        THEN COALESCE((SELECT MAX(o.position) + 1 FROM dishes o WHERE o.section_id = $6), 0)
+		        ELSE d.position
+		    END
+		WHERE d.id = $1 AND d.archived_at IS NULL
+		RETURNING ` + dishSelect
+	var d models.Dishes
+	err := database.RMS.QueryRowx(query, id, name, description, price, restaurantID, sectionID, clearSection).StructScan(&d)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return &d, nil
+}
 
-	var dishes []models.Dishes
-	for rows.Next() {
-		var d models.Dishes
-		if err := rows.Scan(&d.ID, &d.Name, &d.RestaurantID, &d.CreatedBy, &d.Price); err != nil {
-			return nil, err
-		}
-		dishes = append(dishes, d)
+// ArchiveDish reports whether an active dish was archived.
+func ArchiveDish(id uuid.UUID) (bool, error) {
+	res, err := database.RMS.Exec(`UPDATE dishes SET archived_at = CURRENT_TIMESTAMP WHERE id = $1 AND archived_at IS NULL`, id)
+	if err != nil {
+		return false, err
 	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
 
-	return dishes, nil
+// RestoreDish reports whether an archived dish of an active restaurant was restored.
+func RestoreDish(id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE dishes d SET archived_at = NULL
+		WHERE d.id = $1 AND d.archived_at IS NOT NULL
+		  AND EXISTS (SELECT 1 FROM restaurants r WHERE r.id = d.restaurant_id AND r.archived_at IS NULL)
+	`
+	res, err := database.RMS.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
 }
@@ -0,0 +1,20 @@
+package dbHelper
+
+import (
+	"github.com/google/uuid"
+	"rms/database"
+	"rms/models"
+)
+
+func RecordSecurityEvent(event models.SecurityEvent) error {
+	var userID *uuid.UUID
+	if event.UserID != uuid.Nil {
+		userID = &event.UserID
+	}
+	query := `
+		INSERT INTO security_events (event_type, user_id, ip_address, details)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+	`
+	_, err := database.RMS.Exec(query, event.EventType, userID, event.IPAddress, event.Details)
+	return err
+}
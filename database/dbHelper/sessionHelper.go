@@ -1,21 +1,105 @@
 package dbHelper
 
 import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"rms/database"
 	"rms/models"
 )
 
-func GetSessionByToken(token string) (*models.Session, error) {
+const sessionColumns = `id, user_id, refresh_token, family_id, parent_id, expires_at, created_at, rotated_at, revoked_at,
+	user_agent, ip_address, last_used_at`
+
+// GetSessionByToken looks a session up by the SHA-256 hash of its refresh token.
+func GetSessionByToken(tokenHash string) (*models.Session, error) {
 	var session models.Session
-	query := `SELECT * FROM sessions WHERE refresh_token = $1`
-	err := database.RMS.Get(&session, query, token)
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE refresh_token = $1`
+	err := database.RMS.Get(&session, query, tokenHash)
 	if err != nil {
 		return nil, err
 	}
 	return &session, nil
 }
 
-func DeleteSession(refreshToken string) error {
-	_, err := database.RMS.Exec(`DELETE FROM sessions WHERE refresh_token = $1`, refreshToken)
+func InsertSession(db sqlx.Ext, session *models.Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, refresh_token, family_id, parent_id, expires_at, created_at,
+		                      user_agent, ip_address, last_used_at)
+		VALUES (:id, :user_id, :refresh_token, :family_id, :parent_id, :expires_at, :created_at,
+		        :user_agent, :ip_address, :last_used_at)
+	`
+	_, err := sqlx.NamedExec(db, query, session)
+	return err
+}
+
+// MarkSessionRotated retires a session once its child has been issued. It
+// reports false if the session was already rotated or revoked.
+func MarkSessionRotated(db sqlx.Ext, sessionID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE sessions SET rotated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+	`
+	res, err := db.Exec(query, sessionID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RevokeSessionFamily revokes every session descended from the same sign-in.
+func RevokeSessionFamily(familyID uuid.UUID) error {
+	_, err := database.RMS.Exec(`UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL`, familyID)
 	return err
 }
+
+// ListActiveSessions returns the user's signed-in devices, one per token family.
+func ListActiveSessions(userID uuid.UUID) ([]models.SessionInfo, error) {
+	query := `
+		SELECT s.family_id AS id,
+		       (SELECT MIN(f.created_at) FROM sessions f WHERE f.family_id = s.family_id) AS created_at,
+		       COALESCE(s.last_used_at, s.created_at) AS last_used_at,
+		       s.expires_at,
+		       COALESCE(s.user_agent, '') AS user_agent,
+		       COALESCE(s.ip_address, '') AS ip_address
+		FROM sessions s
+		WHERE s.user_id = $1
+		  AND s.rotated_at IS NULL AND s.revoked_at IS NULL
+		  AND s.expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_used_at DESC
+	`
+	sessions := []models.SessionInfo{}
+	err := database.RMS.Select(&sessions, query, userID)
+	return sessions, err
+}
+
+// RevokeUserSessionFamily revokes one of the user's sessions and reports
+// whether it existed.
+func RevokeUserSessionFamily(userID, familyID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL
+	`
+	res, err := database.RMS.Exec(query, userID, familyID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RevokeUserSessions signs the user out of every device and returns the
+// families it revoked.
+func RevokeUserSessions(db sqlx.Ext, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND revoked_at IS NULL
+			RETURNING family_id
+		)
+		SELECT DISTINCT family_id FROM revoked
+	`
+	familyIDs := []uuid.UUID{}
+	err := sqlx.Select(db, &familyIDs, query, userID)
+	return familyIDs, err
+}
@@ -0,0 +1,54 @@
+package dbHelper
+
+import (
+	"database/sql"
+	"errors"
+	"rms/database"
+	"rms/models"
+	"time"
+)
+
+// GetLoginThrottle returns the failure counter for key, or nil if it is clean.
+func GetLoginThrottle(key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	query := `SELECT key, failures, last_failure_at, locked_until FROM login_throttles WHERE key = $1`
+	err := database.RMS.Get(&throttle, query, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// RecordLoginFailure bumps the counter for key, starting over when the last
+// failure is older than windowStart.
+func RecordLoginFailure(key string, now, windowStart time.Time) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	query := `
+		INSERT INTO login_throttles (key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE WHEN login_throttles.last_failure_at < $3 THEN 1 ELSE login_throttles.failures + 1 END,
+		    last_failure_at = EXCLUDED.last_failure_at
+		RETURNING key, failures, last_failure_at, locked_until
+	`
+	err := database.RMS.Get(&throttle, query, key, now, windowStart)
+	return &throttle, err
+}
+
+func LockLoginThrottle(key string, until time.Time) error {
+	_, err := database.RMS.Exec(`UPDATE login_throttles SET locked_until = $2 WHERE key = $1`, key, until)
+	return err
+}
+
+// ClearLoginThrottle forgets past failures, after a successful sign-in or an unlock.
+func ClearLoginThrottle(key string) (bool, error) {
+	res, err := database.RMS.Exec(`DELETE FROM login_throttles WHERE key = $1`, key)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
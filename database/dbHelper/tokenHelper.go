@@ -0,0 +1,43 @@
+package dbHelper
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"rms/database"
+	"time"
+)
+
+func CreateUserToken(id, userID uuid.UUID, purpose string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO user_tokens (id, user_id, purpose, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := database.RMS.Exec(query, id, userID, purpose, expiresAt)
+	return err
+}
+
+// ConsumeUserToken marks an unexpired, unused token as used and reports
+// whether it did, so a token can only ever be redeemed once.
+func ConsumeUserToken(db sqlx.Ext, id, userID uuid.UUID, purpose string) (bool, error) {
+	query := `
+		UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND purpose = $3
+		  AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+	`
+	res, err := db.Exec(query, id, userID, purpose)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RevokeUserTokens burns every outstanding token of the given purpose for a user.
+func RevokeUserTokens(db sqlx.Ext, userID uuid.UUID, purpose string) error {
+	query := `
+		UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`
+	_, err := db.Exec(query, userID, purpose)
+	return err
+}
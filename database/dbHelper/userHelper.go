@@ -1,10 +1,10 @@
 package dbHelper
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"rms/database"
 	"rms/models"
 )
@@ -58,28 +58,29 @@ func IsEmailAlreadyRegistered(email string) (bool, error) {
 	return exists, err
 }
 
-func CreateUser(id uuid.UUID, username, email, hashedPassword string) error {
+// CreateUser inserts a user row; db may be database.RMS or a transaction.
+func CreateUser(db sqlx.Ext, id uuid.UUID, username, email, hashedPassword string) error {
 	query := `INSERT INTO users (id, username, email, password) VALUES ($1, $2, $3, $4)`
-	_, err := database.RMS.Exec(query, id, username, email, hashedPassword)
+	_, err := db.Exec(query, id, username, email, hashedPassword)
 	return err
 }
 
-func AssignRoleToUser(userID uuid.UUID, roleName string) error {
+func AssignRoleToUser(db sqlx.Ext, userID uuid.UUID, roleName string) error {
 	var roleID uuid.UUID
-	err := database.RMS.Get(&roleID, `SELECT id FROM roles WHERE role_name = $1`, roleName)
+	err := sqlx.Get(db, &roleID, `SELECT id FROM roles WHERE role_name = $1`, roleName)
 	if err != nil {
 		return fmt.Errorf("role not found: %s", roleName)
 	}
-	_, err = database.RMS.Exec(`INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2)`, userID, roleID)
+	_, err = db.Exec(`INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2)`, userID, roleID)
 	return err
 }
 
-func InsertAddress(userID uuid.UUID, addr models.AddressRequest) error {
+func InsertAddress(db sqlx.Ext, userID uuid.UUID, addr models.AddressRequest) error {
 	query := `
-	INSERT INTO addresses (id, user_id, label, lat, lng)
-		VALUES ($1, $2, $3, $4, $5)
+	INSERT INTO addresses (id, user_id, label, lat, lng, is_default)
+		VALUES ($1, $2, $3, $4, $5, ` + firstAddressSQL + `)
 	`
-	_, err := database.RMS.Exec(query, uuid.New(), userID, addr.Label, addr.Lat, addr.Lng)
+	_, err := db.Exec(query, uuid.New(), userID, addr.Label, addr.Lat, addr.Lng)
 	return err
 }
 
@@ -98,7 +99,7 @@ func GetUserRoles(userID uuid.UUID) ([]string, error) {
 func GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
 	query := `
-        SELECT id, username, email, password 
+        SELECT id, username, email, password, verified_at
         FROM users 
         WHERE email = $1 AND archived_at IS NULL
     `
@@ -110,67 +111,82 @@ func GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-func InsertUserAddress(userID uuid.UUID, label string, lat, lng float64) (uuid.UUID, error) {
-	id := uuid.New()
+func GetUserByID(userID uuid.UUID) (*models.User, error) {
+	var user models.User
 	query := `
-		INSERT INTO addresses (id, user_id, label, lat, lng)
-		VALUES ($1, $2, $3, $4, $5)
-	`
-	_, err := database.RMS.Exec(query, id, userID, label, lat, lng)
-	return id, err
+        SELECT id, username, email, password, verified_at
+        FROM users
+        WHERE id = $1 AND archived_at IS NULL
+    `
+	err := database.RMS.Get(&user, query, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return &user, nil
 }
 
-func GetUsersByRole(roleName string) ([]models.User, error) {
-	query := `
-		SELECT u.id, u.username, u.email, u.created_at
-		FROM users u
-		JOIN user_roles ur ON u.id = ur.user_id
-		JOIN roles r ON ur.role_id = r.id
-		WHERE LOWER(r.role_name) = LOWER($1)
-	`
+func UpdateUserPassword(db sqlx.Ext, userID uuid.UUID, hashedPassword string) error {
+	_, err := db.Exec(`UPDATE users SET password = $2 WHERE id = $1`, userID, hashedPassword)
+	return err
+}
 
-	rows, err := database.RMS.Query(query, roleName)
+// ArchiveUser soft-deletes a user and reports whether an active user was archived.
+func ArchiveUser(db sqlx.Ext, userID uuid.UUID) (bool, error) {
+	res, err := db.Exec(`UPDATE users SET archived_at = CURRENT_TIMESTAMP WHERE id = $1 AND archived_at IS NULL`, userID)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer rows.Close()
-
-	var users []models.User
-	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt); err != nil {
-			return nil, err
-		}
-		users = append(users, u)
-	}
-	return users, nil
+	n, err := res.RowsAffected()
+	return n == 1, err
 }
 
-// dbHelper/user.go
+func MarkUserVerified(db sqlx.Ext, userID uuid.UUID) error {
+	_, err := db.Exec(`UPDATE users SET verified_at = CURRENT_TIMESTAMP WHERE id = $1 AND verified_at IS NULL`, userID)
+	return err
+}
 
-func GetUsersVisibleTo(requesterID uuid.UUID, isAdmin bool) ([]models.User, error) {
-	var rows *sql.Rows
-	var err error
+func InsertUserAddress(userID uuid.UUID, req models.CreateAddressRequest) (uuid.UUID, error) {
+	id := uuid.New()
+	query := `
+		INSERT INTO addresses (id, user_id, label, lat, lng, is_default, street, city, region, postcode, country)
+		VALUES ($1, $2, $3, $4, $5, ` + firstAddressSQL + `, $6, $7, $8, $9, $10)
+	`
+	a := req.Address
+	_, err := database.RMS.Exec(query, id, userID, req.Label, req.Lat, req.Lng,
+		a.Street, a.City, a.Region, a.Postcode, a.Country)
+	return id, err
+}
 
-	if isAdmin {
-		rows, err = database.RMS.Query(`SELECT id, username, email, created_at FROM users`)
-	} else {
-		rows, err = database.RMS.Query(`SELECT id, username, email, created_at FROM users WHERE created_by = $1`, requesterID)
-	}
+var userList = listSpec[models.User]{
+	selectFrom: `SELECT u.id, u.username, u.email, u.created_at FROM users u`,
+	idColumn:   "u.id",
+	id:         func(u models.User) uuid.UUID { return u.ID },
+	sorts: map[string]sortField[models.User]{
+		"username":   {"u.username", kindText, func(u models.User) interface{} { return u.Username }},
+		"email":      {"u.email", kindText, func(u models.User) interface{} { return u.Email }},
+		"created_at": {"u.created_at", kindTime, func(u models.User) interface{} { return u.CreatedAt }},
+	},
+	defaultSort: "created_at",
+	filters: map[string]filterField{
+		"name":           {"u.username", kindText, opContains},
+		"email":          {"u.email", kindText, opContains},
+		"created_by":     {"u.created_by", kindUUID, opEquals},
+		"created_after":  {"u.created_at", kindTime, opMin},
+		"created_before": {"u.created_at", kindTime, opMax},
+	},
+}
 
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var users []models.User
-	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt); err != nil {
-			return nil, err
-		}
-		users = append(users, u)
-	}
+func GetUsersByRole(roleName string, params models.ListParams) (models.Page[models.User], error) {
+	return listPage(userList, params, []string{`u.id IN (
+		SELECT ur.user_id FROM user_roles ur JOIN roles r ON ur.role_id = r.id
+		WHERE LOWER(r.role_name) = LOWER($1))`}, roleName)
+}
+
+// dbHelper/user.go
 
-	return users, nil
+func GetUsersVisibleTo(requesterID uuid.UUID, seeAll bool, params models.ListParams) (models.Page[models.User], error) {
+	if seeAll {
+		return listPage(userList, params, nil)
+	}
+	return listPage(userList, params, []string{"u.created_by = $1"}, requesterID)
 }
@@ -47,6 +47,20 @@ func ShutdownDB() error {
 	return RMS.Close()
 }
 
+// Tx runs fn inside a single transaction, committing only if fn succeeds.
+func Tx(fn func(tx *sqlx.Tx) error) error {
+	tx, err := RMS.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func migrateUpAndDown(db *sqlx.DB) error {
 	logrus.Info("Migrating database...")
 	dbDriver, dbError := postgres.WithInstance(db.DB, &postgres.Config{})
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- Sessions: which client signed in, and when it last refreshed
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ip_address TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP DEFAULT NULL;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Denylist of access-token jtis and session IDs, kept until the tokens would have expired anyway
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    id TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Failed sign-in counters, keyed by 'account:<email>' or 'ip:<address>'
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP DEFAULT NULL
+);
+
+COMMIT;
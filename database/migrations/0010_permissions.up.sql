@@ -0,0 +1,57 @@
+BEGIN;
+
+-- Built-in roles cannot be deleted or renamed
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS is_system BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE roles SET is_system = TRUE WHERE role_name IN ('admin', 'subadmin', 'user') AND NOT is_system;
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id UUID NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+-- Default grants are only made when a permission is first created, so grants
+-- changed by admins survive this migration being applied again
+WITH new_permissions AS (
+    INSERT INTO permissions (name, description)
+    VALUES
+        ('restaurant:create', 'Create restaurants'),
+        ('restaurant:list', 'List restaurants one manages'),
+        ('restaurant:list_all', 'List every restaurant, archived ones included'),
+        ('restaurant:update', 'Update restaurants'),
+        ('restaurant:delete', 'Archive and restore restaurants'),
+        ('dish:create', 'Create dishes'),
+        ('dish:list', 'List dishes one manages'),
+        ('dish:list_all', 'List every dish, archived ones included'),
+        ('dish:update', 'Update dishes'),
+        ('dish:delete', 'Archive and restore dishes'),
+        ('user:create', 'Create plain users'),
+        ('user:list', 'List users one created'),
+        ('user:list_all', 'List every user'),
+        ('user:archive', 'Archive users'),
+        ('user:unlock', 'Lift sign-in lockouts'),
+        ('user:sessions', 'List and revoke other users'' sessions'),
+        ('subadmin:create', 'Create subadmins'),
+        ('subadmin:list', 'List subadmins'),
+        ('api_key:manage', 'Create, list and revoke API keys'),
+        ('role:manage', 'Manage roles, their permissions and role assignments')
+    ON CONFLICT (name) DO NOTHING
+    RETURNING id, name
+)
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM new_permissions p
+JOIN roles r ON r.role_name = 'admin'
+    OR (r.role_name = 'subadmin' AND p.name IN (
+        'restaurant:create', 'restaurant:list', 'restaurant:update', 'restaurant:delete',
+        'dish:create', 'dish:list', 'dish:update', 'dish:delete',
+        'user:create', 'user:list'))
+ON CONFLICT DO NOTHING;
+
+COMMIT;
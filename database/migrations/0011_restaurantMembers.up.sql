@@ -0,0 +1,41 @@
+BEGIN;
+
+-- Staff of each restaurant; creators become owners once, when the table is new,
+-- so later ownership changes are not undone by re-applying this migration
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.tables WHERE table_name = 'restaurant_members'
+    ) THEN
+        CREATE TABLE restaurant_members (
+            restaurant_id UUID NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+            user_id UUID NOT NULL REFERENCES users(id),
+            role TEXT NOT NULL CHECK (role IN ('owner', 'manager', 'cashier', 'chef')),
+            invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (restaurant_id, user_id)
+        );
+
+        INSERT INTO restaurant_members (restaurant_id, user_id, role)
+        SELECT id, created_by, 'owner' FROM restaurants WHERE created_by IS NOT NULL;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_restaurant_members_user_id ON restaurant_members (user_id);
+
+WITH new_permissions AS (
+    INSERT INTO permissions (name, description)
+    VALUES
+        ('restaurant:members', 'Invite, change and remove restaurant staff'),
+        ('restaurant:manage_all', 'Act on every restaurant without being a member')
+    ON CONFLICT (name) DO NOTHING
+    RETURNING id, name
+)
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM new_permissions p
+JOIN roles r ON r.role_name = 'admin'
+    OR (r.role_name = 'subadmin' AND p.name = 'restaurant:members')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
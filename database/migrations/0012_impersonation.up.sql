@@ -0,0 +1,15 @@
+BEGIN;
+
+WITH new_permissions AS (
+    INSERT INTO permissions (name, description)
+    VALUES ('user:impersonate', 'Act as another user with a short-lived token')
+    ON CONFLICT (name) DO NOTHING
+    RETURNING id, name
+)
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM new_permissions p
+JOIN roles r ON r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+-- Existing rows get the time of this migration
+ALTER TABLE restaurants ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE dishes ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Keyset pagination on the default sort
+CREATE INDEX IF NOT EXISTS idx_restaurants_created_at_id ON restaurants (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_dishes_created_at_id ON dishes (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_dishes_restaurant_id ON dishes (restaurant_id);
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users (created_at, id);
+
+COMMIT;
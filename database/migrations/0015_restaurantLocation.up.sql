@@ -0,0 +1,6 @@
+BEGIN;
+
+-- Bounding-box prefilter for nearby searches
+CREATE INDEX IF NOT EXISTS idx_restaurants_lat_lng ON restaurants (lat, lng) WHERE archived_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Where each restaurant delivers: within delivery_radius_km, and inside
+-- delivery_zone (a GeoJSON Polygon) when one is set
+ALTER TABLE restaurants ADD COLUMN IF NOT EXISTS delivery_radius_km DOUBLE PRECISION NOT NULL DEFAULT 5;
+ALTER TABLE restaurants ADD COLUMN IF NOT EXISTS delivery_zone JSONB;
+
+ALTER TABLE restaurants DROP CONSTRAINT IF EXISTS restaurants_delivery_radius_km_check;
+ALTER TABLE restaurants ADD CONSTRAINT restaurants_delivery_radius_km_check CHECK (delivery_radius_km > 0);
+
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'addresses' AND column_name = 'is_default'
+    ) THEN
+        ALTER TABLE addresses ADD COLUMN is_default BOOLEAN NOT NULL DEFAULT false;
+
+        -- Anyone with a single address already has an obvious default
+        UPDATE addresses a SET is_default = true
+        WHERE a.archived_at IS NULL
+          AND NOT EXISTS (
+              SELECT 1 FROM addresses o
+              WHERE o.user_id = a.user_id AND o.id <> a.id AND o.archived_at IS NULL
+          );
+    END IF;
+END $$;
+
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- At most one default address per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_addresses_one_default ON addresses (user_id) WHERE is_default AND archived_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_addresses_user_id ON addresses (user_id) WHERE archived_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,63 @@
+BEGIN;
+
+-- Local time zone for opening hours, and the manual paused/busy switch
+ALTER TABLE restaurants ADD COLUMN IF NOT EXISTS time_zone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE restaurants ADD COLUMN IF NOT EXISTS is_paused BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE restaurants ADD COLUMN IF NOT EXISTS paused_until TIMESTAMPTZ;
+
+-- Weekly opening intervals in local minutes after midnight (0 = Sunday).
+-- closes_minute < opens_minute runs past midnight into the next day.
+CREATE TABLE IF NOT EXISTS restaurant_hours (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    restaurant_id UUID NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens_minute SMALLINT NOT NULL CHECK (opens_minute >= 0 AND opens_minute < 1440),
+    closes_minute SMALLINT NOT NULL CHECK (closes_minute > 0 AND closes_minute <= 1440),
+    CHECK (opens_minute <> closes_minute)
+);
+CREATE INDEX IF NOT EXISTS idx_restaurant_hours_restaurant_id ON restaurant_hours (restaurant_id);
+
+-- Holidays and ad-hoc closures over [starts_at, ends_at)
+CREATE TABLE IF NOT EXISTS restaurant_closures (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    restaurant_id UUID NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at > starts_at)
+);
+CREATE INDEX IF NOT EXISTS idx_restaurant_closures_restaurant_ends ON restaurant_closures (restaurant_id, ends_at);
+
+-- Whether a restaurant is open at a moment; the same rules as schedule.IsOpen.
+-- A restaurant without weekly hours is open around the clock.
+CREATE OR REPLACE FUNCTION restaurant_is_open(p_restaurant_id UUID, p_at TIMESTAMPTZ) RETURNS BOOLEAN
+LANGUAGE SQL STABLE AS $$
+    SELECT NOT (r.is_paused AND (r.paused_until IS NULL OR p_at < r.paused_until))
+       AND NOT EXISTS (
+           SELECT 1 FROM restaurant_closures c
+           WHERE c.restaurant_id = r.id AND c.starts_at <= p_at AND p_at < c.ends_at
+       )
+       AND (
+           NOT EXISTS (SELECT 1 FROM restaurant_hours h WHERE h.restaurant_id = r.id)
+           OR EXISTS (
+               SELECT 1
+               FROM restaurant_hours h,
+                    LATERAL (SELECT p_at AT TIME ZONE r.time_zone AS t) lt,
+                    LATERAL (
+                        SELECT EXTRACT(DOW FROM lt.t)::int AS today,
+                               (EXTRACT(HOUR FROM lt.t) * 60 + EXTRACT(MINUTE FROM lt.t))::int AS minute
+                    ) l
+               WHERE h.restaurant_id = r.id
+                 AND ((h.weekday = l.today AND h.opens_minute <= l.minute
+                       AND (l.minute < h.closes_minute OR h.closes_minute < h.opens_minute))
+                   OR (h.weekday = (l.today + 6) % 7 AND h.closes_minute < h.opens_minute
+                       AND l.minute < h.closes_minute))
+           )
+       )
+    FROM restaurants r
+    WHERE r.id = p_restaurant_id
+$$;
+
+COMMIT;
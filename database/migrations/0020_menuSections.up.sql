@@ -0,0 +1,20 @@
+BEGIN;
+
+-- Menu sections such as Starters, Mains and Drinks, shown in position order
+CREATE TABLE IF NOT EXISTS menu_sections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    restaurant_id UUID NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_menu_sections_restaurant_name ON menu_sections (restaurant_id, lower(name));
+
+-- Dishes without a section are listed after the sections
+ALTER TABLE dishes ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE dishes ADD COLUMN IF NOT EXISTS section_id UUID REFERENCES menu_sections(id) ON DELETE SET NULL;
+ALTER TABLE dishes ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_dishes_section_id ON dishes (section_id);
+
+COMMIT;
@@ -0,0 +1,36 @@
+BEGIN;
+
+-- Portions such as half and full; a variant's price replaces the dish price
+CREATE TABLE IF NOT EXISTS dish_variants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dish_id UUID NOT NULL REFERENCES dishes(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    price NUMERIC(10, 2) NOT NULL CHECK (price > 0),
+    is_default BOOLEAN NOT NULL DEFAULT false,
+    position INTEGER NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dish_variants_dish_name ON dish_variants (dish_id, lower(name));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dish_variants_default ON dish_variants (dish_id) WHERE is_default;
+
+-- Add-ons such as extra cheese or spice level, picked between min_select and
+-- max_select options per group
+CREATE TABLE IF NOT EXISTS modifier_groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dish_id UUID NOT NULL REFERENCES dishes(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    min_select INTEGER NOT NULL DEFAULT 0 CHECK (min_select >= 0),
+    max_select INTEGER NOT NULL DEFAULT 1 CHECK (max_select >= 1 AND max_select >= min_select),
+    position INTEGER NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_modifier_groups_dish_name ON modifier_groups (dish_id, lower(name));
+
+CREATE TABLE IF NOT EXISTS modifier_options (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES modifier_groups(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    price_delta NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK (price_delta >= 0),
+    position INTEGER NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_modifier_options_group_name ON modifier_options (group_id, lower(name));
+
+COMMIT;
@@ -0,0 +1,67 @@
+// Package geo holds the plain-math geography shared by the handlers and
+// queries: coordinate checks, search bounding boxes and delivery zones.
+// Distances themselves are computed in SQL, with the same spherical formula
+// and KmPerDegree.
+package geo
+
+import "math"
+
+// KmPerDegree is the length of one degree of a great circle.
+const KmPerDegree = 111.111
+
+// ValidCoordinates reports whether lat and lng are within range.
+func ValidCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180 &&
+		!math.IsNaN(lat) && !math.IsNaN(lng)
+}
+
+// Box is a latitude/longitude rectangle. When WrapsLng is set the box crosses
+// the antimeridian, and longitudes match if they are >= MinLng or <= MaxLng.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+	WrapsLng       bool
+}
+
+// BoundingBox returns a box containing every point within radiusKm of
+// (lat, lng). It is meant as an index-friendly prefilter before the exact
+// distance check.
+func BoundingBox(lat, lng, radiusKm float64) Box {
+	dLat := radiusKm / KmPerDegree
+	box := Box{
+		MinLat: math.Max(lat-dLat, -90),
+		MaxLat: math.Min(lat+dLat, 90),
+		MinLng: -180,
+		MaxLng: 180,
+	}
+
+	// Near the poles every longitude may be in reach
+	cosLat := math.Cos(lat * math.Pi / 180)
+	if box.MinLat == -90 || box.MaxLat == 90 || cosLat < 1e-9 {
+		return box
+	}
+	dLng := radiusKm / (KmPerDegree * cosLat)
+	if dLng >= 180 {
+		return box
+	}
+
+	box.MinLng, box.MaxLng = lng-dLng, lng+dLng
+	if box.MinLng < -180 {
+		box.MinLng += 360
+		box.WrapsLng = true
+	}
+	if box.MaxLng > 180 {
+		box.MaxLng -= 360
+		box.WrapsLng = true
+	}
+	return box
+}
+
+// DistanceKm is the great-circle distance between two points, by the same
+// formula the SQL queries use.
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	cos := math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Cos((lng1-lng2)*rad) +
+		math.Sin(lat1*rad)*math.Sin(lat2*rad)
+	return KmPerDegree * math.Acos(math.Max(-1, math.Min(1, cos))) / rad
+}
@@ -0,0 +1,90 @@
+package geo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Polygon is a GeoJSON Polygon. Positions are [lng, lat]; the first ring is
+// the outer boundary and any further rings are holes. Rings are treated as
+// flat on a lat/lng grid, which is accurate enough at delivery-zone scale, and
+// may not cross the antimeridian.
+type Polygon struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// Validate checks that p is a well-formed GeoJSON Polygon: closed rings of at
+// least four positions, all within range.
+func (p Polygon) Validate() error {
+	if p.Type != "Polygon" {
+		return errors.New(`type must be "Polygon"`)
+	}
+	if len(p.Coordinates) == 0 {
+		return errors.New("coordinates must have at least one ring")
+	}
+	for i, ring := range p.Coordinates {
+		if len(ring) < 4 {
+			return fmt.Errorf("ring %d must have at least 4 positions", i)
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("ring %d must end where it starts", i)
+		}
+		for _, pos := range ring {
+			if !ValidCoordinates(pos[1], pos[0]) {
+				return fmt.Errorf("ring %d has a position out of range; positions are [lng, lat]", i)
+			}
+		}
+	}
+	return nil
+}
+
+// Contains reports whether (lat, lng) lies inside the outer ring and outside
+// every hole.
+func (p Polygon) Contains(lat, lng float64) bool {
+	if len(p.Coordinates) == 0 || !ringContains(p.Coordinates[0], lat, lng) {
+		return false
+	}
+	for _, hole := range p.Coordinates[1:] {
+		if ringContains(hole, lat, lng) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains is the even-odd ray casting test: count the edges a ray
+// going east from the point crosses.
+func ringContains(ring [][2]float64, lat, lng float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		lngI, latI := ring[i][0], ring[i][1]
+		lngJ, latJ := ring[j][0], ring[j][1]
+		if (latI > lat) != (latJ > lat) &&
+			lng < (lngJ-lngI)*(lat-latI)/(latJ-latI)+lngI {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Value stores the polygon as GeoJSON text; lib/pq would send []byte as
+// bytea, which a jsonb column rejects.
+func (p Polygon) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	return string(b), err
+}
+
+// Scan reads a polygon stored as GeoJSON.
+func (p *Polygon) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("geo: cannot scan %T into Polygon", src)
+	}
+}
@@ -0,0 +1,287 @@
+package geocode
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"rms/geo"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxReverseKm is how far Reverse looks for the nearest entry.
+const DefaultMaxReverseKm = 25
+
+// Gazetteer is a Geocoder over a list of known places held in memory, such
+// as a city list or a street-level CSV export.
+type Gazetteer struct {
+	// MaxReverseKm is how far Reverse looks for the nearest entry.
+	MaxReverseKm float64
+
+	entries    []entry // by latitude, for Reverse
+	byCity     map[string][]int
+	byPostcode map[string][]int
+}
+
+type entry struct {
+	place      Place
+	population int64
+	key        Address // normalized, for matching
+}
+
+// normalize folds case and whitespace so that lookups match loosely typed input.
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func normalizeAddress(a Address) Address {
+	return Address{
+		Street:   normalize(a.Street),
+		City:     normalize(a.City),
+		Region:   normalize(a.Region),
+		Postcode: normalize(a.Postcode),
+		Country:  normalize(a.Country),
+	}
+}
+
+// newGazetteer indexes entries; altNames adds further city names per entry.
+func newGazetteer(entries []entry, altNames map[int][]string) *Gazetteer {
+	g := &Gazetteer{
+		MaxReverseKm: DefaultMaxReverseKm,
+		byCity:       make(map[string][]int),
+		byPostcode:   make(map[string][]int),
+	}
+
+	// Sort by latitude first so the indexes point at final positions
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return entries[order[a]].place.Lat < entries[order[b]].place.Lat
+	})
+	g.entries = make([]entry, len(entries))
+	for pos, i := range order {
+		e := entries[i]
+		g.entries[pos] = e
+		if e.key.City != "" {
+			g.byCity[e.key.City] = append(g.byCity[e.key.City], pos)
+		}
+		for _, name := range altNames[i] {
+			if name = normalize(name); name != "" && name != e.key.City {
+				g.byCity[name] = append(g.byCity[name], pos)
+			}
+		}
+		if e.key.Postcode != "" {
+			g.byPostcode[e.key.Postcode] = append(g.byPostcode[e.key.Postcode], pos)
+		}
+	}
+	return g
+}
+
+// Len is the number of places loaded.
+func (g *Gazetteer) Len() int {
+	return len(g.entries)
+}
+
+// Geocode finds the entry best matching addr by postcode or city, preferring
+// one on the same street, then matching region and country, then the most
+// populous. The returned address takes the gazetteer's spelling where the
+// parts agree, and keeps what was asked for where they do not.
+func (g *Gazetteer) Geocode(addr Address) (Place, error) {
+	q := normalizeAddress(addr)
+	candidates := append(append([]int(nil), g.byPostcode[q.Postcode]...), g.byCity[q.City]...)
+
+	best, bestScore := -1, -1
+	for _, i := range candidates {
+		e := g.entries[i].key
+		score := 0
+		if q.Street != "" && e.Street != "" {
+			if q.Street != e.Street {
+				continue
+			}
+			score += 8
+		}
+		if q.Postcode != "" && q.Postcode == e.Postcode {
+			score += 4
+		}
+		if q.City != "" && q.City == e.City {
+			score += 2
+		}
+		if q.Region != "" && q.Region == e.Region {
+			score++
+		}
+		if q.Country != "" && q.Country == e.Country {
+			score++
+		}
+		if score > bestScore || (score == bestScore && g.entries[i].population > g.entries[best].population) {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return Place{}, ErrNotFound
+	}
+
+	found := g.entries[best].place
+	place := Place{Address: addr, Lat: found.Lat, Lng: found.Lng}
+	fill := func(dst *string, src string) {
+		if *dst == "" || normalize(*dst) == normalize(src) {
+			*dst = src
+		}
+	}
+	fill(&place.Street, found.Street)
+	fill(&place.City, found.City)
+	fill(&place.Region, found.Region)
+	fill(&place.Postcode, found.Postcode)
+	fill(&place.Country, found.Country)
+	return place, nil
+}
+
+// Reverse returns the entry nearest to (lat, lng) within MaxReverseKm.
+func (g *Gazetteer) Reverse(lat, lng float64) (Place, error) {
+	dLat := g.MaxReverseKm / geo.KmPerDegree
+	start := sort.Search(len(g.entries), func(i int) bool {
+		return g.entries[i].place.Lat >= lat-dLat
+	})
+
+	best, bestKm := -1, g.MaxReverseKm
+	for i := start; i < len(g.entries) && g.entries[i].place.Lat <= lat+dLat; i++ {
+		p := g.entries[i].place
+		if km := geo.DistanceKm(lat, lng, p.Lat, p.Lng); km <= bestKm {
+			best, bestKm = i, km
+		}
+	}
+	if best < 0 {
+		return Place{}, ErrNotFound
+	}
+	return g.entries[best].place, nil
+}
+
+// LoadCSV reads a gazetteer from CSV with a header row. lat and lng (or
+// latitude and longitude/lon) are required; street, city, region, postcode,
+// country and population are used when present.
+func LoadCSV(r io.Reader) (*Gazetteer, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("gazetteer: reading header: %w", err)
+	}
+	col := make(map[string]int)
+	for i, name := range header {
+		col[normalize(name)] = i
+	}
+	pick := func(names ...string) int {
+		for _, name := range names {
+			if i, ok := col[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	latCol, lngCol := pick("lat", "latitude"), pick("lng", "lon", "longitude")
+	if latCol < 0 || lngCol < 0 {
+		return nil, errors.New("gazetteer: CSV needs lat and lng columns")
+	}
+	streetCol, cityCol, regionCol := pick("street"), pick("city"), pick("region", "state")
+	postcodeCol, countryCol, populationCol := pick("postcode", "postal_code", "zip"), pick("country"), pick("population")
+
+	var entries []entry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer: %w", err)
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		lat, latErr := strconv.ParseFloat(field(latCol), 64)
+		lng, lngErr := strconv.ParseFloat(field(lngCol), 64)
+		if latErr != nil || lngErr != nil || !geo.ValidCoordinates(lat, lng) {
+			return nil, fmt.Errorf("gazetteer: line %d: invalid coordinates", line)
+		}
+		population, _ := strconv.ParseInt(field(populationCol), 10, 64)
+		addr := Address{
+			Street:   field(streetCol),
+			City:     field(cityCol),
+			Region:   field(regionCol),
+			Postcode: field(postcodeCol),
+			Country:  field(countryCol),
+		}
+		entries = append(entries, entry{
+			place:      Place{Address: addr, Lat: lat, Lng: lng},
+			population: population,
+			key:        normalizeAddress(addr),
+		})
+	}
+	return newGazetteer(entries, nil), nil
+}
+
+// GeoNames dump columns (https://download.geonames.org/export/dump/readme.txt)
+const (
+	gnName          = 1
+	gnASCIIName     = 2
+	gnLat           = 4
+	gnLng           = 5
+	gnFeatureClass  = 6
+	gnCountryCode   = 8
+	gnAdmin1Code    = 10
+	gnPopulation    = 14
+	gnColumnCount   = 19
+	gnPopulatedArea = "P"
+)
+
+// LoadGeoNames reads a GeoNames dump such as cities500.txt. Only populated
+// places are kept, as city-level entries: the region is the admin1 code and
+// the country its ISO code. ASCII names are indexed too.
+func LoadGeoNames(r io.Reader) (*Gazetteer, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var entries []entry
+	altNames := make(map[int][]string)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < gnColumnCount {
+			return nil, fmt.Errorf("gazetteer: line %d: expected %d columns, got %d", line, gnColumnCount, len(fields))
+		}
+		if fields[gnFeatureClass] != gnPopulatedArea {
+			continue
+		}
+		lat, latErr := strconv.ParseFloat(fields[gnLat], 64)
+		lng, lngErr := strconv.ParseFloat(fields[gnLng], 64)
+		if latErr != nil || lngErr != nil || !geo.ValidCoordinates(lat, lng) {
+			return nil, fmt.Errorf("gazetteer: line %d: invalid coordinates", line)
+		}
+		population, _ := strconv.ParseInt(fields[gnPopulation], 10, 64)
+		addr := Address{
+			City:    fields[gnName],
+			Region:  fields[gnAdmin1Code],
+			Country: fields[gnCountryCode],
+		}
+		if fields[gnASCIIName] != "" {
+			altNames[len(entries)] = []string{fields[gnASCIIName]}
+		}
+		entries = append(entries, entry{
+			place:      Place{Address: addr, Lat: lat, Lng: lng},
+			population: population,
+			key:        normalizeAddress(addr),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("gazetteer: %w", err)
+	}
+	return newGazetteer(entries, altNames), nil
+}
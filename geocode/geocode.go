@@ -0,0 +1,97 @@
+// Package geocode turns structured addresses into coordinates and back.
+package geocode
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when a lookup has no match.
+var ErrNotFound = errors.New("geocode: no match")
+
+// Address is a structured postal address. Any part may be empty.
+type Address struct {
+	Street   string `db:"street" json:"street,omitempty"`
+	City     string `db:"city" json:"city,omitempty"`
+	Region   string `db:"region" json:"region,omitempty"`
+	Postcode string `db:"postcode" json:"postcode,omitempty"`
+	Country  string `db:"country" json:"country,omitempty"`
+}
+
+// IsZero reports whether no part of the address is set.
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+// Trimmed returns the address with surrounding whitespace removed.
+func (a Address) Trimmed() Address {
+	return Address{
+		Street:   strings.TrimSpace(a.Street),
+		City:     strings.TrimSpace(a.City),
+		Region:   strings.TrimSpace(a.Region),
+		Postcode: strings.TrimSpace(a.Postcode),
+		Country:  strings.TrimSpace(a.Country),
+	}
+}
+
+// Place is an address together with where it is.
+type Place struct {
+	Address
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Geocoder looks up the coordinates of an address, and the address nearest
+// to a point.
+type Geocoder interface {
+	Geocode(addr Address) (Place, error)
+	Reverse(lat, lng float64) (Place, error)
+}
+
+// Default is the geocoder used by the handlers, or nil when none is
+// configured; main replaces it with FromEnv().
+var Default Geocoder
+
+// FromEnv loads the gazetteer named by GAZETTEER_FILE, or returns nil when it
+// is unset. GAZETTEER_FORMAT is csv or geonames; by default files ending in
+// .txt are read as GeoNames dumps and anything else as CSV.
+// GAZETTEER_MAX_REVERSE_KM bounds reverse lookups.
+func FromEnv() (Geocoder, error) {
+	path := os.Getenv("GAZETTEER_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	format := os.Getenv("GAZETTEER_FORMAT")
+	if format == "" {
+		format = "csv"
+		if strings.HasSuffix(strings.ToLower(path), ".txt") {
+			format = "geonames"
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var g *Gazetteer
+	switch format {
+	case "csv":
+		g, err = LoadCSV(f)
+	case "geonames":
+		g, err = LoadGeoNames(f)
+	default:
+		return nil, fmt.Errorf("unknown GAZETTEER_FORMAT %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if km, err := strconv.ParseFloat(os.Getenv("GAZETTEER_MAX_REVERSE_KM"), 64); err == nil && km > 0 {
+		g.MaxReverseKm = km
+	}
+	return g, nil
+}
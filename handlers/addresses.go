@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/geo"
+	"rms/middleware"
+	"rms/models"
+	"strings"
+)
+
+// validAddressPoint rejects coordinates out of range and 0,0, which is what
+// an unset lat/lng decodes to rather than anywhere people live.
+func validAddressPoint(w http.ResponseWriter, lat, lng float64) bool {
+	if !geo.ValidCoordinates(lat, lng) {
+		http.Error(w, "lat must be within ±90 and lng within ±180", http.StatusBadRequest)
+		return false
+	}
+	if lat == 0 && lng == 0 {
+		http.Error(w, "lat and lng are required", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func pathAddressID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	addressID, err := uuid.Parse(mux.Vars(r)["address_id"])
+	if err != nil {
+		http.Error(w, "Invalid address ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return addressID, true
+}
+
+// ListMyAddresses returns the caller's address book, default first.
+func ListMyAddresses(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	addresses, err := dbHelper.ListUserAddresses(userID)
+	if err != nil {
+		logrus.Errorf("Error listing addresses of %s: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(addresses)
+}
+
+// UpdateMyAddress changes the label, coordinates and/or structured address of
+// one of the caller's addresses.
+func UpdateMyAddress(w http.ResponseWriter, r *http.Request) {
+	addressID, ok := pathAddressID(w, r)
+	if !ok {
+		return
+	}
+	var req models.UpdateAddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Label == nil && req.Lat == nil && req.Lng == nil && req.Address == nil {
+		http.Error(w, "label, lat, lng or address is required", http.StatusBadRequest)
+		return
+	}
+	if req.Label != nil {
+		label := strings.TrimSpace(*req.Label)
+		req.Label = &label
+	}
+	if !relocate(w, &req.Lat, &req.Lng, &req.Address) {
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	address, err := dbHelper.UpdateUserAddress(userID, addressID, req)
+	if err != nil {
+		logrus.Errorf("Error updating address %s: %v", addressID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if address == nil {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(address)
+}
+
+// ArchiveMyAddress removes an address from the caller's address book.
+func ArchiveMyAddress(w http.ResponseWriter, r *http.Request) {
+	addressID, ok := pathAddressID(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	archived, err := dbHelper.ArchiveUserAddress(userID, addressID)
+	if err != nil {
+		logrus.Errorf("Error archiving address %s: %v", addressID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !archived {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Address archived successfully",
+	})
+}
+
+// SetMyDefaultAddress makes one of the caller's addresses their default, the
+// one used when a request leaves address_id out.
+func SetMyDefaultAddress(w http.ResponseWriter, r *http.Request) {
+	addressID, ok := pathAddressID(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var found bool
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		found, err = dbHelper.SetDefaultAddress(tx, userID, addressID)
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("Error setting default address %s: %v", addressID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Default address updated successfully",
+		"address_id": addressID,
+	})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database/dbHelper"
+	"rms/middleware"
+	"rms/models"
+	"rms/utils"
+	"strings"
+	"time"
+)
+
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	// Normalise and de-duplicate roles and scopes
+	roles := uniqueLower(req.Roles)
+	scopes := uniqueLower(req.Scopes)
+	if len(roles) == 0 && len(scopes) == 0 {
+		http.Error(w, "roles or scopes are required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range scopes {
+		if scope != models.ScopeRead && scope != models.ScopeWrite {
+			http.Error(w, "scopes may only contain read and write", http.StatusBadRequest)
+			return
+		}
+	}
+	if len(roles) > 0 {
+		count, err := dbHelper.CountRoles(roles)
+		if err != nil {
+			logrus.Errorf("Error checking roles: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if count != len(roles) {
+			http.Error(w, "unknown role in roles", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The key acts as this account, e.g. as created_by of what it creates
+	userID := adminID
+	if req.UserID != "" {
+		parsed, err := uuid.Parse(req.UserID)
+		if err != nil {
+			http.Error(w, "Invalid user_id format", http.StatusBadRequest)
+			return
+		}
+		if _, err := dbHelper.GetUserByID(parsed); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		userID = parsed
+	}
+
+	rawKey, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	key := models.APIKey{
+		ID:        uuid.New(),
+		Name:      req.Name,
+		KeyPrefix: prefix,
+		UserID:    userID,
+		Roles:     roles,
+		Scopes:    scopes,
+		CreatedBy: adminID,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := dbHelper.CreateAPIKey(&key, utils.HashToken(rawKey)); err != nil {
+		logrus.Errorf("Error creating API key: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	logrus.Infof("admin %s created API key %s (%s)", adminID, key.ID, key.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.CreateAPIKeyResponse{
+		Message: "API key created, store it now as it will not be shown again",
+		Key:     rawKey,
+		APIKey:  key,
+	})
+}
+
+func ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := dbHelper.ListAPIKeys()
+	if err != nil {
+		logrus.Errorf("Error listing API keys: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := uuid.Parse(mux.Vars(r)["key_id"])
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := dbHelper.RevokeAPIKey(keyID)
+	if err != nil {
+		logrus.Errorf("Error revoking API key: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "API key revoked",
+	})
+}
+
+// uniqueLower trims, lowercases and de-duplicates values, keeping their order.
+func uniqueLower(values []string) []string {
+	seen := make(map[string]bool)
+	out := []string{}
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
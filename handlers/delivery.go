@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database/dbHelper"
+	"rms/geo"
+	"rms/middleware"
+	"rms/models"
+	"rms/utils"
+	"strconv"
+)
+
+const defaultDeliveryRadiusKm = 5
+
+// validDeliveryArea checks a delivery radius and zone that are being set;
+// nil means unchanged. ok is false once an error has been written.
+func validDeliveryArea(w http.ResponseWriter, radiusKm *float64, zone *geo.Polygon) bool {
+	if radiusKm != nil {
+		maxRadius := float64(utils.EnvInt("DELIVERY_MAX_RADIUS_KM", 50))
+		if *radiusKm <= 0 || *radiusKm > maxRadius {
+			http.Error(w, "delivery_radius_km must be greater than 0 and at most "+strconv.FormatFloat(maxRadius, 'f', -1, 64), http.StatusBadRequest)
+			return false
+		}
+	}
+	if zone != nil {
+		if err := zone.Validate(); err != nil {
+			http.Error(w, "Invalid delivery_zone: "+err.Error(), http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
+
+// GetRestaurantServiceability reports whether a restaurant delivers to one of
+// the caller's addresses: it must be within the delivery radius and, if the
+// restaurant has one, inside its delivery zone.
+func GetRestaurantServiceability(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	addressID, err := uuid.Parse(r.URL.Query().Get("address_id"))
+	if err != nil {
+		http.Error(w, "address_id is required", http.StatusBadRequest)
+		return
+	}
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	lat, lng, found, err := dbHelper.GetUserAddressPoint(userID, addressID)
+	if err != nil {
+		logrus.Errorf("Error loading address %s: %v", addressID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	restaurant, err := dbHelper.GetRestaurantFromPoint(restaurantID, lat, lng)
+	if err != nil {
+		logrus.Errorf("Error loading restaurant %s: %v", restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if restaurant == nil {
+		http.Error(w, "Restaurant not found", http.StatusNotFound)
+		return
+	}
+
+	resp := models.Serviceability{
+		RestaurantID:     restaurantID,
+		AddressID:        addressID,
+		Serviceable:      restaurant.DeliversTo(lat, lng, restaurant.DistanceKm),
+		DistanceKm:       restaurant.DistanceKm,
+		DeliveryRadiusKm: restaurant.DeliveryRadiusKm,
+		HasDeliveryZone:  restaurant.DeliveryZone != nil,
+	}
+	if restaurant.DeliveryZone != nil {
+		inZone := restaurant.DeliveryZone.Contains(lat, lng)
+		resp.InDeliveryZone = &inZone
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
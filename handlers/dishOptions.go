@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/models"
+	"strconv"
+	"strings"
+)
+
+// maxQuoteQuantity bounds how many of a dish one quote prices.
+const maxQuoteQuantity = 100
+
+// validDishOptions trims names and checks the selection rules of a dish
+// configuration. ok is false once an error has been written.
+func validDishOptions(w http.ResponseWriter, req *models.SetDishOptionsRequest) bool {
+	fail := func(format string, args ...interface{}) bool {
+		http.Error(w, fmt.Sprintf(format, args...), http.StatusBadRequest)
+		return false
+	}
+	// unique reports whether a name is new among those seen, ignoring case
+	unique := func(seen map[string]bool, name string) bool {
+		key := strings.ToLower(name)
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		return true
+	}
+
+	variants, defaults := make(map[string]bool), 0
+	for i := range req.Variants {
+		v := &req.Variants[i]
+		v.Name = strings.TrimSpace(v.Name)
+		if v.Name == "" {
+			return fail("every variant needs a name")
+		}
+		if !unique(variants, v.Name) {
+			return fail("variant %q is listed twice", v.Name)
+		}
+		if v.Price <= 0 {
+			return fail("variant %q: price must be greater than 0", v.Name)
+		}
+		if v.IsDefault {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return fail("at most one variant can be the default")
+	}
+
+	groups := make(map[string]bool)
+	for i := range req.ModifierGroups {
+		g := &req.ModifierGroups[i]
+		g.Name = strings.TrimSpace(g.Name)
+		if g.Name == "" {
+			return fail("every modifier group needs a name")
+		}
+		if !unique(groups, g.Name) {
+			return fail("modifier group %q is listed twice", g.Name)
+		}
+		if len(g.Options) == 0 {
+			return fail("%s: a modifier group needs at least one option", g.Name)
+		}
+		if g.MinSelect < 0 || g.MaxSelect < 1 || g.MaxSelect < g.MinSelect {
+			return fail("%s: need 0 <= min_select <= max_select and max_select >= 1", g.Name)
+		}
+		if g.MinSelect > len(g.Options) {
+			return fail("%s: min_select is more than the number of options", g.Name)
+		}
+		options := make(map[string]bool)
+		for j := range g.Options {
+			o := &g.Options[j]
+			o.Name = strings.TrimSpace(o.Name)
+			if o.Name == "" {
+				return fail("%s: every option needs a name", g.Name)
+			}
+			if !unique(options, o.Name) {
+				return fail("%s: option %q is listed twice", g.Name, o.Name)
+			}
+			if o.PriceDelta < 0 {
+				return fail("%s: option %q: price_delta cannot be negative", g.Name, o.Name)
+			}
+		}
+	}
+	return true
+}
+
+// loadDishOptions answers 404 for a dish that is not on any menu. ok is false
+// once an error has been written.
+func loadDishOptions(w http.ResponseWriter, dishID uuid.UUID) (*models.DishOptions, bool) {
+	options, err := dbHelper.GetDishOptions(dishID)
+	if err != nil {
+		logrus.Errorf("Error loading options of dish %s: %v", dishID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if options == nil {
+		http.Error(w, "Dish not found", http.StatusNotFound)
+		return nil, false
+	}
+	return options, true
+}
+
+// GetDishOptions returns a dish's variants and modifier groups.
+func GetDishOptions(w http.ResponseWriter, r *http.Request) {
+	dishID, ok := pathDishID(w, r)
+	if !ok {
+		return
+	}
+	options, ok := loadDishOptions(w, dishID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// SetDishOptions replaces a dish's variants and modifier groups. Menu editors
+// only.
+func SetDishOptions(w http.ResponseWriter, r *http.Request) {
+	dishID, ok := pathDishID(w, r)
+	if !ok {
+		return
+	}
+	var req models.SetDishOptionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validDishOptions(w, &req) {
+		return
+	}
+	if _, ok := dishAccess(w, r, dishID, false); !ok {
+		return
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		return dbHelper.SetDishOptions(tx, dishID, req)
+	})
+	if err != nil {
+		logrus.Errorf("Error setting options of dish %s: %v", dishID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	options, ok := loadDishOptions(w, dishID)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// QuoteDish validates a chosen variant and add-ons against a dish's rules and
+// returns the price, for quantity (default 1) of them.
+func QuoteDish(w http.ResponseWriter, r *http.Request) {
+	dishID, ok := pathDishID(w, r)
+	if !ok {
+		return
+	}
+	var req models.QuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+	if req.Quantity < 0 || req.Quantity > maxQuoteQuantity {
+		http.Error(w, "quantity must be between 1 and "+strconv.Itoa(maxQuoteQuantity), http.StatusBadRequest)
+		return
+	}
+	var variantID *uuid.UUID
+	if req.VariantID != nil {
+		parsed, err := uuid.Parse(*req.VariantID)
+		if err != nil {
+			http.Error(w, "Invalid variant_id format", http.StatusBadRequest)
+			return
+		}
+		variantID = &parsed
+	}
+	optionIDs := make([]uuid.UUID, 0, len(req.OptionIDs))
+	for _, raw := range req.OptionIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "Invalid ID in option_ids: "+raw, http.StatusBadRequest)
+			return
+		}
+		optionIDs = append(optionIDs, id)
+	}
+
+	options, ok := loadDishOptions(w, dishID)
+	if !ok {
+		return
+	}
+	quote, err := options.Quote(variantID, optionIDs, req.Quantity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quote)
+}
@@ -1,21 +1,28 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"net/http"
 	"rms/database/dbHelper"
 	"rms/middleware"
+	"rms/models"
+	"rms/utils"
 )
 
+// GetDistanceFromAddress returns the distance from one of the caller's
+// addresses, their default one if address_id is left out, to a restaurant.
 func GetDistanceFromAddress(w http.ResponseWriter, r *http.Request) {
 	// Get query params
 	restaurantID := r.URL.Query().Get("restaurant_id")
 	addressID := r.URL.Query().Get("address_id")
 
-	if restaurantID == "" || addressID == "" {
-		http.Error(w, "restaurant_id and address_id are required", http.StatusBadRequest)
+	if restaurantID == "" {
+		http.Error(w, "restaurant_id is required", http.StatusBadRequest)
 		return
 	}
 
@@ -25,11 +32,6 @@ func GetDistanceFromAddress(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid restaurant_id", http.StatusBadRequest)
 		return
 	}
-	addrID, err := uuid.Parse(addressID)
-	if err != nil {
-		http.Error(w, "Invalid address_id", http.StatusBadRequest)
-		return
-	}
 
 	// Get user ID from context
 	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
@@ -38,8 +40,33 @@ func GetDistanceFromAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var addrID uuid.UUID
+	if addressID != "" {
+		addrID, err = uuid.Parse(addressID)
+		if err != nil {
+			http.Error(w, "Invalid address_id", http.StatusBadRequest)
+			return
+		}
+	} else {
+		var found bool
+		addrID, found, err = dbHelper.GetDefaultAddressID(userID)
+		if err != nil {
+			logrus.Errorf("Error loading default address of %s: %v", userID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "address_id is required when no default address is set", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Call DB helper to compute distance
 	distance, err := dbHelper.GetDistanceBetweenAddressAndRestaurant(userID, addrID, restID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Address or restaurant not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		logrus.Errorf("Error fetching distance: %v", err)
 		http.Error(w, "Error fetching distance", http.StatusInternalServerError)
@@ -49,7 +76,97 @@ func GetDistanceFromAddress(w http.ResponseWriter, r *http.Request) {
 	// Respond
 	resp := map[string]interface{}{
 		"distance_km": distance,
+		"address_id":  addrID,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseMatrixIDs parses and de-duplicates a list of IDs, keeping their order.
+func parseMatrixIDs(raw []string) ([]uuid.UUID, bool) {
+	ids := make([]uuid.UUID, 0, len(raw))
+	seen := make(map[uuid.UUID]bool, len(raw))
+	for _, s := range raw {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, false
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, true
+}
+
+// GetDistanceMatrix returns the distance from each of the caller's addresses
+// to each restaurant. Every address must be the caller's own.
+func GetDistanceMatrix(w http.ResponseWriter, r *http.Request) {
+	var req models.DistanceMatrixRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	addressIDs, ok := parseMatrixIDs(req.AddressIDs)
+	if !ok {
+		http.Error(w, "Invalid address_ids", http.StatusBadRequest)
+		return
+	}
+	restaurantIDs, ok := parseMatrixIDs(req.RestaurantIDs)
+	if !ok {
+		http.Error(w, "Invalid restaurant_ids", http.StatusBadRequest)
+		return
+	}
+	if len(addressIDs) == 0 || len(restaurantIDs) == 0 {
+		http.Error(w, "address_ids and restaurant_ids are required", http.StatusBadRequest)
+		return
+	}
+	maxIDs := utils.EnvInt("DISTANCE_MATRIX_MAX_IDS", 100)
+	if len(addressIDs) > maxIDs || len(restaurantIDs) > maxIDs {
+		http.Error(w, fmt.Sprintf("at most %d address_ids and %d restaurant_ids are allowed", maxIDs, maxIDs), http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
 	}
+
+	cells, err := dbHelper.GetDistanceMatrix(userID, addressIDs, restaurantIDs)
+	if err != nil {
+		logrus.Errorf("Error fetching distance matrix: %v", err)
+		http.Error(w, "Error fetching distance", http.StatusInternalServerError)
+		return
+	}
+
+	distances := make(map[[2]uuid.UUID]float64, len(cells))
+	for _, cell := range cells {
+		if !cell.AddressOK {
+			http.Error(w, "Address not found: "+cell.AddressID.String(), http.StatusNotFound)
+			return
+		}
+		if !cell.RestaurantOK {
+			http.Error(w, "Restaurant not found: "+cell.RestaurantID.String(), http.StatusNotFound)
+			return
+		}
+		distances[[2]uuid.UUID{cell.AddressID, cell.RestaurantID}] = *cell.DistanceKm
+	}
+
+	resp := models.DistanceMatrixResponse{
+		AddressIDs:    addressIDs,
+		RestaurantIDs: restaurantIDs,
+		DistancesKm:   make([][]float64, len(addressIDs)),
+	}
+	for i, addressID := range addressIDs {
+		row := make([]float64, len(restaurantIDs))
+		for j, restaurantID := range restaurantIDs {
+			row[j] = distances[[2]uuid.UUID{addressID, restaurantID}]
+		}
+		resp.DistancesKm[i] = row
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/geocode"
+)
+
+// locate completes a location given as coordinates, a structured address or
+// both. Coordinates alone are reverse geocoded, leaving the address empty if
+// nothing is known nearby; an address alone is geocoded and must be found.
+// Coordinates are checked as in validAddressPoint. ok is false once an error
+// has been written.
+func locate(w http.ResponseWriter, lat, lng float64, addr geocode.Address) (geocode.Place, bool) {
+	addr = addr.Trimmed()
+	hasPoint := lat != 0 || lng != 0
+
+	if hasPoint {
+		if !validAddressPoint(w, lat, lng) {
+			return geocode.Place{}, false
+		}
+		place := geocode.Place{Address: addr, Lat: lat, Lng: lng}
+		if !addr.IsZero() || geocode.Default == nil {
+			return place, true
+		}
+		found, err := geocode.Default.Reverse(lat, lng)
+		if err != nil && !errors.Is(err, geocode.ErrNotFound) {
+			logrus.Errorf("Error reverse geocoding %f,%f: %v", lat, lng, err)
+		}
+		if err == nil {
+			place.Address = found.Address
+		}
+		return place, true
+	}
+
+	if addr.IsZero() {
+		http.Error(w, "lat and lng or an address is required", http.StatusBadRequest)
+		return geocode.Place{}, false
+	}
+	if geocode.Default == nil {
+		http.Error(w, "lat and lng are required: addresses cannot be looked up on this server", http.StatusBadRequest)
+		return geocode.Place{}, false
+	}
+	place, err := geocode.Default.Geocode(addr)
+	if errors.Is(err, geocode.ErrNotFound) {
+		http.Error(w, "Address could not be located; send lat and lng instead", http.StatusUnprocessableEntity)
+		return geocode.Place{}, false
+	}
+	if err != nil {
+		logrus.Errorf("Error geocoding %+v: %v", addr, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return geocode.Place{}, false
+	}
+	return place, true
+}
+
+// relocate is locate for PATCH requests, where lat, lng and addr are nil when
+// unchanged. Moving the coordinates without an address replaces the stored
+// address with whatever is found there. ok is false once an error has been
+// written.
+func relocate(w http.ResponseWriter, lat, lng **float64, addr **geocode.Address) bool {
+	if *lat == nil && *lng == nil && *addr == nil {
+		return true
+	}
+	if (*lat == nil) != (*lng == nil) {
+		http.Error(w, "lat and lng must be changed together", http.StatusBadRequest)
+		return false
+	}
+
+	var pointLat, pointLng float64
+	if *lat != nil {
+		pointLat, pointLng = **lat, **lng
+		if pointLat == 0 && pointLng == 0 {
+			http.Error(w, "lat and lng cannot both be zero", http.StatusBadRequest)
+			return false
+		}
+	}
+	var given geocode.Address
+	if *addr != nil {
+		given = **addr
+	}
+
+	place, ok := locate(w, pointLat, pointLng, given)
+	if !ok {
+		return false
+	}
+	*lat, *lng, *addr = &place.Lat, &place.Lng, &place.Address
+	return true
+}
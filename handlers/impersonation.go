@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database/dbHelper"
+	"rms/middleware"
+	"rms/models"
+	"rms/utils"
+	"strings"
+	"time"
+)
+
+// ImpersonateUser gives an admin a short-lived access token that acts as
+// another user, for seeing exactly what they see. The token carries an act
+// claim naming the admin, cannot be refreshed and is refused by account-level
+// routes. An optional reason is kept with the audit record.
+func ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	var req models.ImpersonateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Only a signed-in admin, not an API key or another impersonation
+	ctx := r.Context()
+	adminID, ok := ctx.Value(middleware.UserIDKey).(uuid.UUID)
+	sessionID, hasSession := ctx.Value(middleware.SessionIDKey).(uuid.UUID)
+	if !ok || !hasSession || ctx.Value(middleware.ActorIDKey) != nil {
+		http.Error(w, "Forbidden: impersonation needs an admin's own session", http.StatusForbidden)
+		return
+	}
+	if adminID == targetID {
+		http.Error(w, "You cannot impersonate yourself", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := dbHelper.GetUserByID(targetID); err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	roles, err := dbHelper.GetUserRoles(targetID)
+	if err != nil {
+		http.Error(w, "failed to fetch roles", http.StatusInternalServerError)
+		return
+	}
+	for _, role := range roles {
+		if strings.EqualFold(role, models.SuperRole) {
+			http.Error(w, "Admins cannot be impersonated", http.StatusForbidden)
+			return
+		}
+	}
+
+	ttl := utils.EnvDuration("IMPERSONATION_TTL", 10*time.Minute)
+	token, expiresAt, err := utils.GenerateImpersonationJWT(targetID, adminID, sessionID, roles, ttl)
+	if err != nil {
+		http.Error(w, "failed to generate access token", http.StatusInternalServerError)
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	logrus.WithFields(logrus.Fields{
+		"user_id":  targetID,
+		"actor_id": adminID,
+		"reason":   reason,
+	}).Warn("security event: admin started impersonating user")
+	details := fmt.Sprintf("admin %s until %s", adminID, expiresAt.UTC().Format(time.RFC3339))
+	if reason != "" {
+		details += ": " + reason
+	}
+	event := models.SecurityEvent{
+		EventType: models.EventImpersonation,
+		UserID:    targetID,
+		IPAddress: utils.ClientIP(r),
+		Details:   details,
+	}
+	if err := dbHelper.RecordSecurityEvent(event); err != nil {
+		logrus.Errorf("failed to record security event: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ImpersonationResponse{
+		Message:     "Impersonation token issued",
+		AccessToken: token,
+		ExpiresAt:   expiresAt,
+		UserID:      targetID,
+		ActorID:     adminID,
+	})
+}
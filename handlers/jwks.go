@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/utils"
+)
+
+// JWKSHandler publishes the token verification keys so other services can
+// check access tokens without calling us.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	jwks, err := utils.PublicJWKS()
+	if err != nil {
+		logrus.Errorf("Failed to build JWKS: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(jwks)
+}
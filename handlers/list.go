@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database/dbHelper"
+	"rms/models"
+	"strconv"
+)
+
+// parseListParams reads limit, cursor, sort and order from the query string.
+// Every other parameter is a filter, except the endpoint's own in reserved.
+func parseListParams(w http.ResponseWriter, r *http.Request, reserved ...string) (models.ListParams, bool) {
+	query := r.URL.Query()
+	params := models.ListParams{
+		Cursor:  query.Get("cursor"),
+		Sort:    query.Get("sort"),
+		Filters: make(map[string]string),
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > models.MaxListLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(models.MaxListLimit), http.StatusBadRequest)
+			return params, false
+		}
+		params.Limit = limit
+	}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		params.Desc = true
+	default:
+		http.Error(w, "order must be asc or desc", http.StatusBadRequest)
+		return params, false
+	}
+
+	skip := map[string]bool{"limit": true, "cursor": true, "sort": true, "order": true}
+	for _, name := range reserved {
+		skip[name] = true
+	}
+	for name, values := range query {
+		if !skip[name] && len(values) > 0 && values[0] != "" {
+			params.Filters[name] = values[0]
+		}
+	}
+	return params, true
+}
+
+// writePage answers with a page, or with the error that prevented it.
+func writePage[T any](w http.ResponseWriter, page models.Page[T], err error, what string) {
+	if errors.Is(err, dbHelper.ErrInvalidListParams) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to fetch %s: %v", what, err)
+		http.Error(w, "Failed to fetch "+what, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/models"
+	"strings"
+)
+
+func pathSectionID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	sectionID, err := uuid.Parse(mux.Vars(r)["section_id"])
+	if err != nil {
+		http.Error(w, "Invalid section ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return sectionID, true
+}
+
+// sectionInRestaurant checks that a dish's section_id names a section of the
+// restaurant serving it. ok is false once an error has been written.
+func sectionInRestaurant(w http.ResponseWriter, sectionID, restaurantID uuid.UUID) bool {
+	owner, err := dbHelper.GetMenuSectionRestaurantID(sectionID)
+	if err != nil {
+		logrus.Errorf("Error loading menu section %s: %v", sectionID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if owner != restaurantID {
+		http.Error(w, "section_id is not a section of this restaurant's menu", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// parseOrderIDs reads a reorder request's ids, rejecting repeats.
+func parseOrderIDs(w http.ResponseWriter, r *http.Request) ([]uuid.UUID, bool) {
+	var req models.MenuOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	ids := make([]uuid.UUID, 0, len(req.IDs))
+	seen := make(map[uuid.UUID]bool, len(req.IDs))
+	for _, raw := range req.IDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "Invalid ID in ids: "+raw, http.StatusBadRequest)
+			return nil, false
+		}
+		if seen[id] {
+			http.Error(w, "ids lists "+raw+" more than once", http.StatusBadRequest)
+			return nil, false
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// CreateMenuSection adds a section at the end of a restaurant's menu.
+func CreateMenuSection(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	var req models.CreateMenuSectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if _, ok := restaurantAccess(w, r, restaurantID, models.MenuEditors...); !ok {
+		return
+	}
+
+	section, err := dbHelper.CreateMenuSection(restaurantID, req.Name, strings.TrimSpace(req.Description))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "The menu already has a section with this name", http.StatusConflict)
+			return
+		}
+		logrus.Errorf("Error creating menu section: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Section created successfully",
+		"section": section,
+	})
+}
+
+// UpdateMenuSection renames a section or changes its description.
+func UpdateMenuSection(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	sectionID, ok := pathSectionID(w, r)
+	if !ok {
+		return
+	}
+	var req models.UpdateMenuSectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == nil && req.Description == nil {
+		http.Error(w, "name or description is required", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			http.Error(w, "name cannot be empty", http.StatusBadRequest)
+			return
+		}
+		req.Name = &name
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		req.Description = &description
+	}
+	if _, ok := restaurantAccess(w, r, restaurantID, models.MenuEditors...); !ok {
+		return
+	}
+
+	section, err := dbHelper.UpdateMenuSection(restaurantID, sectionID, req.Name, req.Description)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "The menu already has a section with this name", http.StatusConflict)
+			return
+		}
+		logrus.Errorf("Error updating menu section %s: %v", sectionID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if section == nil {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(section)
+}
+
+// DeleteMenuSection removes a section; its dishes stay on the menu outside
+// any section.
+func DeleteMenuSection(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	sectionID, ok := pathSectionID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := restaurantAccess(w, r, restaurantID, models.MenuEditors...); !ok {
+		return
+	}
+
+	deleted, err := dbHelper.DeleteMenuSection(restaurantID, sectionID)
+	if err != nil {
+		logrus.Errorf("Error deleting menu section %s: %v", sectionID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Section deleted successfully",
+	})
+}
+
+// ReorderMenuSections sets the order of a restaurant's sections; ids must
+// list every section once.
+func ReorderMenuSections(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	ids, ok := parseOrderIDs(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := restaurantAccess(w, r, restaurantID, models.MenuEditors...); !ok {
+		return
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		return dbHelper.ReorderMenuSections(tx, restaurantID, ids)
+	})
+	if errors.Is(err, dbHelper.ErrMenuOrderMismatch) {
+		http.Error(w, "ids must list every section of the menu exactly once", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error reordering sections of %s: %v", restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Sections reordered successfully",
+	})
+}
+
+// ReorderSectionDishes sets the order of the dishes in a section; ids must
+// list every active dish of the section once.
+func ReorderSectionDishes(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	sectionID, ok := pathSectionID(w, r)
+	if !ok {
+		return
+	}
+	ids, ok := parseOrderIDs(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := restaurantAccess(w, r, restaurantID, models.MenuEditors...); !ok {
+		return
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		return dbHelper.ReorderSectionDishes(tx, restaurantID, sectionID, ids)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, dbHelper.ErrMenuOrderMismatch) {
+		http.Error(w, "ids must list every dish of the section exactly once", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error reordering dishes of section %s: %v", sectionID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Dishes reordered successfully",
+	})
+}
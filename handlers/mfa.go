@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/middleware"
+	"rms/models"
+	"rms/utils"
+	"time"
+)
+
+const recoveryCodeCount = 10
+
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "RMS"
+}
+
+// writeMFAChallenge answers a correct password with a short-lived challenge
+// token that /signin/2fa exchanges, together with a code, for real tokens.
+func writeMFAChallenge(w http.ResponseWriter, userID uuid.UUID) {
+	ttl := utils.EnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute)
+	token, err := utils.GeneratePurposeToken(uuid.New(), userID, models.PurposeMFAChallenge, ttl)
+	if err != nil {
+		http.Error(w, "failed to generate challenge", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MFAChallengeResponse{
+		Message:     "Two-factor authentication required",
+		MFARequired: true,
+		MFAToken:    token,
+	})
+}
+
+// checkTOTP validates code for an enabled enrollment and burns its time step.
+func checkTOTP(mfa *models.UserMFA, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return dbHelper.UseMFAStep(mfa.UserID, step)
+}
+
+// newRecoveryCodes generates a fresh set and returns the codes with their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// enabledMFA loads the caller's enrollment, answering for them if 2FA is not enabled.
+func enabledMFA(w http.ResponseWriter, userID uuid.UUID) (*models.UserMFA, bool) {
+	mfa, err := dbHelper.GetUserMFA(userID)
+	if err != nil {
+		logrus.Errorf("Error loading 2FA for %s: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if mfa == nil || mfa.EnabledAt == nil {
+		http.Error(w, "two-factor authentication is not enabled", http.StatusBadRequest)
+		return nil, false
+	}
+	return mfa, true
+}
+
+func LoginMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		http.Error(w, "mfa_token and code or recovery_code are required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := utils.ParsePurposeToken(req.MFAToken, models.PurposeMFAChallenge)
+	if err != nil {
+		http.Error(w, "invalid or expired mfa_token", http.StatusUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid or expired mfa_token", http.StatusUnauthorized)
+		return
+	}
+
+	// The account may have been archived since the password step
+	user, err := dbHelper.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "invalid or expired mfa_token", http.StatusUnauthorized)
+		return
+	}
+	if !checkLoginAllowed(w, r, user.Email) {
+		return
+	}
+	mfa, err := dbHelper.GetUserMFA(userID)
+	if err != nil {
+		logrus.Errorf("Error loading 2FA for %s: %v", userID, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if mfa == nil || mfa.EnabledAt == nil {
+		http.Error(w, "invalid or expired mfa_token", http.StatusUnauthorized)
+		return
+	}
+
+	var valid bool
+	if req.Code != "" {
+		valid, err = checkTOTP(mfa, req.Code)
+	} else {
+		valid, err = dbHelper.UseRecoveryCode(userID, utils.HashToken(utils.NormalizeRecoveryCode(req.RecoveryCode)))
+	}
+	if err != nil {
+		logrus.Errorf("Error checking 2FA code for %s: %v", userID, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		recordLoginFailure(r, user.Email, userID)
+		http.Error(w, "invalid two-factor code", http.StatusUnauthorized)
+		return
+	}
+
+	roles, err := dbHelper.GetUserRoles(userID)
+	if err != nil {
+		http.Error(w, "failed to fetch roles", http.StatusInternalServerError)
+		return
+	}
+
+	clearLoginFailures(user.Email)
+	writeTokenResponse(w, r, userID, roles, "User logged in Successfully", http.StatusOK)
+}
+
+func EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, err := dbHelper.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	started, err := dbHelper.StartMFAEnrollment(userID, secret)
+	if err != nil {
+		logrus.Errorf("Error starting 2FA enrollment for %s: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !started {
+		http.Error(w, "two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(secret, user.Email, mfaIssuer()),
+	})
+}
+
+func ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	mfa, err := dbHelper.GetUserMFA(userID)
+	if err != nil {
+		logrus.Errorf("Error loading 2FA for %s: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if mfa == nil {
+		http.Error(w, "start enrollment at /me/2fa/enroll first", http.StatusBadRequest)
+		return
+	}
+	if mfa.EnabledAt != nil {
+		http.Error(w, "two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	step, valid := utils.ValidateTOTP(mfa.Secret, req.Code, time.Now())
+	if !valid {
+		http.Error(w, "invalid two-factor code", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		if err := dbHelper.EnableMFA(tx, userID, step); err != nil {
+			return err
+		}
+		return dbHelper.ReplaceRecoveryCodes(tx, userID, hashes)
+	})
+	if err != nil {
+		logrus.Errorf("Error enabling 2FA for %s: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodesResponse{
+		Message:       "Two-factor authentication enabled, store these recovery codes safely",
+		RecoveryCodes: codes,
+	})
+}
+
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	mfa, ok := enabledMFA(w, userID)
+	if !ok {
+		return
+	}
+
+	valid, err := checkTOTP(mfa, req.Code)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "invalid two-factor code", http.StatusForbidden)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err == nil {
+		err = dbHelper.ReplaceRecoveryCodes(database.RMS, userID, hashes)
+	}
+	if err != nil {
+		logrus.Errorf("Error replacing recovery codes for %s: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodesResponse{
+		Message:       "Recovery codes regenerated, the old ones no longer work",
+		RecoveryCodes: codes,
+	})
+}
+
+func DisableMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	roles, _ := r.Context().Value(middleware.RolesKey).([]string)
+	if middleware.MFARequired(roles) {
+		http.Error(w, "two-factor authentication is mandatory for your role", http.StatusForbidden)
+		return
+	}
+	mfa, ok := enabledMFA(w, userID)
+	if !ok {
+		return
+	}
+
+	valid, err := checkTOTP(mfa, req.Code)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "invalid two-factor code", http.StatusForbidden)
+		return
+	}
+
+	if err := database.Tx(func(tx *sqlx.Tx) error { return dbHelper.DisableMFA(tx, userID) }); err != nil {
+		logrus.Errorf("Error disabling 2FA for %s: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Two-factor authentication disabled",
+	})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database/dbHelper"
+	"rms/geo"
+	"rms/middleware"
+	"rms/models"
+	"rms/utils"
+	"strconv"
+)
+
+const defaultNearbyRadiusKm = 5
+
+// searchPoint reads the point a search is centred on: one of the caller's
+// addresses (?address_id=) or explicit ?lat=&lng=. ok is false once an error
+// has been written.
+func searchPoint(w http.ResponseWriter, r *http.Request) (float64, float64, bool) {
+	query := r.URL.Query()
+	if rawID := query.Get("address_id"); rawID != "" {
+		addressID, err := uuid.Parse(rawID)
+		if err != nil {
+			http.Error(w, "Invalid address_id", http.StatusBadRequest)
+			return 0, 0, false
+		}
+		userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return 0, 0, false
+		}
+		lat, lng, found, err := dbHelper.GetUserAddressPoint(userID, addressID)
+		if err != nil {
+			logrus.Errorf("Error loading address %s: %v", addressID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return 0, 0, false
+		}
+		if !found {
+			http.Error(w, "Address not found", http.StatusNotFound)
+			return 0, 0, false
+		}
+		return lat, lng, true
+	}
+
+	lat, latErr := strconv.ParseFloat(query.Get("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(query.Get("lng"), 64)
+	if latErr != nil || lngErr != nil {
+		http.Error(w, "address_id or lat and lng are required", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	if !geo.ValidCoordinates(lat, lng) {
+		http.Error(w, "lat must be within ±90 and lng within ±180", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+// GetNearbyRestaurants lists restaurants within radius_km of an address or
+// point, nearest first, with their distance_km. delivers=true keeps only the
+// restaurants that deliver there, and open_now=true those open now.
+func GetNearbyRestaurants(w http.ResponseWriter, r *http.Request) {
+	params, ok := parseListParams(w, r, "address_id", "lat", "lng", "radius_km", "delivers", "open_now")
+	if !ok {
+		return
+	}
+	if (params.Sort != "" && params.Sort != "distance") || params.Desc || len(params.Filters) > 0 {
+		http.Error(w, "nearby restaurants are always sorted by distance and take no other filters", http.StatusBadRequest)
+		return
+	}
+
+	maxRadius := float64(utils.EnvInt("NEARBY_MAX_RADIUS_KM", 50))
+	radius := float64(defaultNearbyRadiusKm)
+	if raw := r.URL.Query().Get("radius_km"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 || parsed > maxRadius {
+			http.Error(w, "radius_km must be greater than 0 and at most "+strconv.FormatFloat(maxRadius, 'f', -1, 64), http.StatusBadRequest)
+			return
+		}
+		radius = parsed
+	}
+
+	deliversOnly := false
+	if raw := r.URL.Query().Get("delivers"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "delivers must be true or false", http.StatusBadRequest)
+			return
+		}
+		deliversOnly = parsed
+	}
+
+	openNow := false
+	if raw := r.URL.Query().Get("open_now"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "open_now must be true or false", http.StatusBadRequest)
+			return
+		}
+		openNow = parsed
+	}
+
+	lat, lng, ok := searchPoint(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := dbHelper.FindNearbyRestaurants(lat, lng, radius, deliversOnly, openNow, params)
+	if err == nil {
+		restaurants := make([]*models.Restaurant, len(page.Items))
+		for i := range page.Items {
+			restaurants[i] = &page.Items[i].Restaurant
+		}
+		err = fillNextOpensAt(restaurants)
+	}
+	writePage(w, page, err, "nearby restaurants")
+}
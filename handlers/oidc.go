@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/models"
+	"rms/oidc"
+	"rms/utils"
+	"strings"
+	"time"
+)
+
+// errNoLinkedAccount means the identity matched no user and auto-provisioning is off.
+var errNoLinkedAccount = errors.New("no account is linked to this identity")
+
+func oidcProvider(w http.ResponseWriter) (*oidc.Provider, bool) {
+	if oidc.Default == nil {
+		http.Error(w, "single sign-on is not configured", http.StatusNotFound)
+		return nil, false
+	}
+	return oidc.Default, true
+}
+
+// OIDCLoginHandler starts an authorization-code + PKCE login. Browsers are
+// redirected to the provider; clients asking for JSON get the URL instead.
+func OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidcProvider(w)
+	if !ok {
+		return
+	}
+
+	login := models.OIDCLoginState{ExpiresAt: time.Now().Add(utils.EnvDuration("OIDC_STATE_TTL", 10*time.Minute))}
+	var err error
+	if login.State, err = oidc.RandomString(32); err == nil {
+		if login.Nonce, err = oidc.RandomString(32); err == nil {
+			login.CodeVerifier, err = oidc.RandomString(32)
+		}
+	}
+	if err != nil {
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), login.State, login.Nonce, oidc.CodeChallenge(login.CodeVerifier))
+	if err != nil {
+		logrus.Errorf("Error preparing OIDC login: %v", err)
+		http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	if err := dbHelper.CreateOIDCLoginState(&login); err != nil {
+		logrus.Errorf("Error saving OIDC login state: %v", err)
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.OIDCLoginResponse{AuthorizationURL: authURL})
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallbackHandler completes the login started by OIDCLoginHandler and
+// answers like /signin: a token pair, or a 2FA challenge when enabled.
+func OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidcProvider(w)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if idpErr := query.Get("error"); idpErr != "" {
+		http.Error(w, "sign-in was not completed: "+idpErr, http.StatusUnauthorized)
+		return
+	}
+	code, state := query.Get("code"), query.Get("state")
+	if code == "" || state == "" {
+		http.Error(w, "code and state are required", http.StatusBadRequest)
+		return
+	}
+
+	login, err := dbHelper.ConsumeOIDCLoginState(state)
+	if err != nil {
+		logrus.Errorf("Error loading OIDC login state: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if login == nil {
+		http.Error(w, "invalid or expired login state", http.StatusBadRequest)
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(r.Context(), code, login.CodeVerifier)
+	if err != nil {
+		logrus.Warnf("OIDC code exchange failed: %v", err)
+		http.Error(w, "failed to complete sign-in", http.StatusUnauthorized)
+		return
+	}
+	claims, err := provider.VerifyIDToken(r.Context(), rawIDToken, login.Nonce)
+	if err != nil {
+		logrus.Warnf("OIDC id_token rejected: %v", err)
+		http.Error(w, "failed to complete sign-in", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := resolveOIDCUser(provider, claims)
+	if errors.Is(err, errNoLinkedAccount) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error linking OIDC identity %s: %v", claims.Subject, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Second factor still applies to accounts that enabled it
+	mfaEnabled, err := dbHelper.IsMFAEnabled(userID)
+	if err != nil {
+		logrus.Errorf("Error checking 2FA for %s: %v", userID, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if mfaEnabled {
+		writeMFAChallenge(w, userID)
+		return
+	}
+
+	roles, err := dbHelper.GetUserRoles(userID)
+	if err != nil {
+		http.Error(w, "failed to fetch roles", http.StatusInternalServerError)
+		return
+	}
+	writeTokenResponse(w, r, userID, roles, "User logged in Successfully", http.StatusOK)
+}
+
+// resolveOIDCUser finds the local user for a verified ID token: an existing
+// link first, then an account with the same verified email, then, if enabled,
+// a newly provisioned user.
+func resolveOIDCUser(provider *oidc.Provider, claims *oidc.IDTokenClaims) (uuid.UUID, error) {
+	userID, err := dbHelper.GetIdentityUserID(provider.Issuer(), claims.Subject)
+	if err != nil || userID != uuid.Nil {
+		return userID, err
+	}
+
+	email := strings.TrimSpace(strings.ToLower(claims.Email))
+	identity := models.UserIdentity{Issuer: provider.Issuer(), Subject: claims.Subject}
+	if email != "" {
+		identity.Email = &email
+	}
+
+	// Only an email the provider vouches for may claim an existing account
+	if email != "" && claims.IsEmailVerified() {
+		if user, err := dbHelper.GetUserByEmail(email); err == nil {
+			identity.UserID = user.ID
+			err = database.Tx(func(tx *sqlx.Tx) error {
+				if err := dbHelper.LinkUserIdentity(tx, identity); err != nil {
+					return err
+				}
+				return dbHelper.MarkUserVerified(tx, user.ID)
+			})
+			return user.ID, err
+		}
+	}
+
+	if !provider.AutoProvision() || email == "" {
+		return uuid.Nil, errNoLinkedAccount
+	}
+	return provisionOIDCUser(identity, email, claims)
+}
+
+// provisionOIDCUser creates a plain user for a new identity. The account gets
+// a random password, so it signs in through the provider until one is set.
+func provisionOIDCUser(identity models.UserIdentity, email string, claims *oidc.IDTokenClaims) (uuid.UUID, error) {
+	username := strings.TrimSpace(claims.Name)
+	if username == "" {
+		username = claims.PreferredUsername
+	}
+	if username == "" {
+		username = strings.SplitN(email, "@", 2)[0]
+	}
+
+	secret, err := oidc.RandomString(32)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	hashedPassword, err := utils.HashPassword(secret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	identity.UserID = uuid.New()
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		if err := dbHelper.CreateUser(tx, identity.UserID, username, email, hashedPassword); err != nil {
+			return err
+		}
+		if err := dbHelper.AssignRoleToUser(tx, identity.UserID, "user"); err != nil {
+			return err
+		}
+		if claims.IsEmailVerified() {
+			if err := dbHelper.MarkUserVerified(tx, identity.UserID); err != nil {
+				return err
+			}
+		}
+		return dbHelper.LinkUserIdentity(tx, identity)
+	})
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		// The email belongs to an account we could not safely link
+		return uuid.Nil, errNoLinkedAccount
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	logrus.Infof("provisioned user %s for %s at %s", identity.UserID, identity.Subject, identity.Issuer)
+	return identity.UserID, nil
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/middleware"
+	"rms/models"
+	"rms/schedule"
+	"strings"
+	"time"
+)
+
+// maxClosureDuration bounds a single closure; longer breaks are archives.
+const maxClosureDuration = 366 * 24 * time.Hour
+
+// fillNextOpensAt sets next_opens_at on the restaurants that are closed now.
+func fillNextOpensAt(restaurants []*models.Restaurant) error {
+	ids := make([]uuid.UUID, 0, len(restaurants))
+	for _, restaurant := range restaurants {
+		if !restaurant.IsOpenNow {
+			ids = append(ids, restaurant.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	schedules, err := dbHelper.GetRestaurantSchedules(ids, now)
+	if err != nil {
+		return err
+	}
+	for _, restaurant := range restaurants {
+		if s, ok := schedules[restaurant.ID]; ok && !restaurant.IsOpenNow {
+			restaurant.NextOpensAt = s.NextOpen(now)
+		}
+	}
+	return nil
+}
+
+func restaurantPointers(restaurants []models.Restaurant) []*models.Restaurant {
+	ptrs := make([]*models.Restaurant, len(restaurants))
+	for i := range restaurants {
+		ptrs[i] = &restaurants[i]
+	}
+	return ptrs
+}
+
+// writeOpeningHours answers with a restaurant's schedule and current status.
+func writeOpeningHours(w http.ResponseWriter, restaurantID uuid.UUID) {
+	now := time.Now()
+	schedules, err := dbHelper.GetRestaurantSchedules([]uuid.UUID{restaurantID}, now)
+	if err != nil {
+		logrus.Errorf("Error loading schedule of %s: %v", restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s, ok := schedules[restaurantID]
+	if !ok {
+		http.Error(w, "Restaurant not found", http.StatusNotFound)
+		return
+	}
+	closures, err := dbHelper.ListRestaurantClosures(restaurantID, now)
+	if err != nil {
+		logrus.Errorf("Error loading closures of %s: %v", restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := models.OpeningHours{
+		RestaurantID: restaurantID,
+		TimeZone:     s.Location.String(),
+		Weekly:       make([]models.OpeningInterval, 0, len(s.Intervals)),
+		Closures:     closures,
+		IsPaused:     s.IsPaused(now),
+		IsOpenNow:    s.IsOpen(now),
+	}
+	if resp.IsPaused {
+		resp.PausedUntil = s.PausedUntil
+	}
+	for _, iv := range s.Intervals {
+		resp.Weekly = append(resp.Weekly, models.OpeningInterval{
+			Day:    strings.ToLower(iv.Weekday.String()),
+			Opens:  schedule.FormatClock(iv.Opens),
+			Closes: schedule.FormatClock(iv.Closes),
+		})
+	}
+	if !resp.IsOpenNow {
+		resp.NextOpensAt = s.NextOpen(now)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetOpeningHours returns a restaurant's weekly hours, upcoming closures and
+// whether it is open now.
+func GetOpeningHours(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	writeOpeningHours(w, restaurantID)
+}
+
+// SetOpeningHours replaces a restaurant's weekly hours and optionally its time
+// zone. Owners and managers only.
+func SetOpeningHours(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	var req models.SetOpeningHoursRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.TimeZone != nil {
+		tz := strings.TrimSpace(*req.TimeZone)
+		if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+			http.Error(w, "time_zone must be an IANA time zone such as Asia/Kolkata", http.StatusBadRequest)
+			return
+		}
+		req.TimeZone = &tz
+	}
+	intervals := make([]schedule.Interval, 0, len(req.Weekly))
+	for _, entry := range req.Weekly {
+		day, err := schedule.ParseWeekday(entry.Day)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opens, err := schedule.ParseClock(entry.Opens)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		closes, err := schedule.ParseClock(entry.Closes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		iv := schedule.Interval{Weekday: day, Opens: opens, Closes: closes}
+		if err := iv.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		intervals = append(intervals, iv)
+	}
+
+	if _, ok := restaurantAccess(w, r, restaurantID, models.RestaurantEditors...); !ok {
+		return
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		return dbHelper.SetOpeningHours(tx, restaurantID, req.TimeZone, intervals)
+	})
+	if errors.Is(err, dbHelper.ErrUnknownTimeZone) {
+		http.Error(w, "time_zone is not known to the database", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error setting opening hours of %s: %v", restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeOpeningHours(w, restaurantID)
+}
+
+// AddRestaurantClosure closes a restaurant for a holiday or an ad-hoc break,
+// given as starts_at/ends_at or as local dates. Owners and managers only.
+func AddRestaurantClosure(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	var req models.CreateClosureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, ok := restaurantAccess(w, r, restaurantID, models.RestaurantEditors...); !ok {
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+
+	var startsAt, endsAt time.Time
+	switch {
+	case req.Date != "" && req.StartsAt == nil && req.EndsAt == nil:
+		// Whole local days, in the restaurant's time zone
+		schedules, err := dbHelper.GetRestaurantSchedules([]uuid.UUID{restaurantID}, time.Now())
+		if err != nil {
+			logrus.Errorf("Error loading schedule of %s: %v", restaurantID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		loc := schedules[restaurantID].Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		if req.EndDate == "" {
+			req.EndDate = req.Date
+		}
+		first, errFirst := time.ParseInLocation("2006-01-02", req.Date, loc)
+		last, errLast := time.ParseInLocation("2006-01-02", req.EndDate, loc)
+		if errFirst != nil || errLast != nil {
+			http.Error(w, "date and end_date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		startsAt, endsAt = first, last.AddDate(0, 0, 1)
+	case req.Date == "" && req.StartsAt != nil && req.EndsAt != nil:
+		startsAt, endsAt = *req.StartsAt, *req.EndsAt
+	default:
+		http.Error(w, "give either starts_at and ends_at, or date (and end_date)", http.StatusBadRequest)
+		return
+	}
+	if !endsAt.After(startsAt) {
+		http.Error(w, "a closure must end after it starts", http.StatusBadRequest)
+		return
+	}
+	if !endsAt.After(time.Now()) {
+		http.Error(w, "this closure is already over", http.StatusBadRequest)
+		return
+	}
+	if endsAt.Sub(startsAt) > maxClosureDuration {
+		http.Error(w, "a closure can last at most a year; archive the restaurant instead", http.StatusBadRequest)
+		return
+	}
+
+	closure, err := dbHelper.AddRestaurantClosure(restaurantID, startsAt, endsAt, strings.TrimSpace(req.Reason), userID)
+	if err != nil {
+		logrus.Errorf("Error adding closure to %s: %v", restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Closure added successfully",
+		"closure": closure,
+	})
+}
+
+// RemoveRestaurantClosure cancels a closure. Owners and managers only.
+func RemoveRestaurantClosure(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	closureID, err := uuid.Parse(mux.Vars(r)["closure_id"])
+	if err != nil {
+		http.Error(w, "Invalid closure ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := restaurantAccess(w, r, restaurantID, models.RestaurantEditors...); !ok {
+		return
+	}
+
+	removed, err := dbHelper.RemoveRestaurantClosure(restaurantID, closureID)
+	if err != nil {
+		logrus.Errorf("Error removing closure %s: %v", closureID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Closure not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Closure removed successfully",
+	})
+}
+
+// SetRestaurantPause switches a restaurant to paused/busy and back. Any member
+// of its staff may, since it is usually the kitchen or till that is swamped.
+func SetRestaurantPause(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	var req models.PauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var until *time.Time
+	switch {
+	case !req.Paused && (req.Until != nil || req.ForMinutes != 0):
+		http.Error(w, "until and for_minutes only apply when pausing", http.StatusBadRequest)
+		return
+	case req.Until != nil && req.ForMinutes != 0:
+		http.Error(w, "give until or for_minutes, not both", http.StatusBadRequest)
+		return
+	case req.Until != nil:
+		if !req.Until.After(time.Now()) {
+			http.Error(w, "until must be in the future", http.StatusBadRequest)
+			return
+		}
+		until = req.Until
+	case req.ForMinutes < 0:
+		http.Error(w, "for_minutes must be positive", http.StatusBadRequest)
+		return
+	case req.ForMinutes > 0:
+		t := time.Now().Add(time.Duration(req.ForMinutes) * time.Minute)
+		until = &t
+	}
+
+	if _, ok := restaurantAccess(w, r, restaurantID); !ok {
+		return
+	}
+
+	found, err := dbHelper.SetRestaurantPause(restaurantID, req.Paused, until)
+	if err != nil {
+		logrus.Errorf("Error pausing %s: %v", restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Restaurant not found", http.StatusNotFound)
+		return
+	}
+
+	message := "Restaurant resumed successfully"
+	if req.Paused {
+		message = "Restaurant paused successfully"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      message,
+		"is_paused":    req.Paused,
+		"paused_until": until,
+	})
+}
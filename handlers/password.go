@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/mailer"
+	"rms/middleware"
+	"rms/models"
+	"rms/utils"
+	"strings"
+	"time"
+)
+
+const minPasswordLength = 8
+
+func validateNewPassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("new_password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+// setPassword stores a new password hash and signs the user out everywhere.
+func setPassword(userID uuid.UUID, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	var familyIDs []uuid.UUID
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		if err := dbHelper.UpdateUserPassword(tx, userID, hashedPassword); err != nil {
+			return err
+		}
+		if err := dbHelper.RevokeUserTokens(tx, userID, models.PurposePasswordReset); err != nil {
+			return err
+		}
+		familyIDs, err = dbHelper.RevokeUserSessions(tx, userID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return utils.DenySessions(familyIDs...)
+}
+
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	if req.Email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	// Same answer whether or not the account exists, so emails can't be probed
+	if user, err := dbHelper.GetUserByEmail(req.Email); err == nil {
+		ttl := utils.EnvDuration("PASSWORD_RESET_TTL", 30*time.Minute)
+		token, err := issueMailedToken(user.ID, models.PurposePasswordReset, ttl)
+		if err == nil {
+			err = mailer.Default.Send(mailer.Message{
+				To:      user.Email,
+				Subject: "Reset your password",
+				Body: fmt.Sprintf("Someone asked to reset your password. If it was you, open the link below within %s:\n\n%s/password/reset?token=%s\n\nReset token: %s\n\nOtherwise you can ignore this email.\n",
+					ttl, appBaseURL(), token, token),
+			})
+		}
+		if err != nil {
+			logrus.Errorf("Error sending password reset to %s: %v", user.Email, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "If the account exists, a password reset email has been sent",
+	})
+}
+
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateNewPassword(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	claims, err := utils.ParsePurposeToken(req.Token, models.PurposePasswordReset)
+	if err != nil {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	consumed, err := dbHelper.ConsumeUserToken(database.RMS, tokenID, userID, models.PurposePasswordReset)
+	if err != nil {
+		logrus.Errorf("Error consuming reset token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !consumed {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	if err := setPassword(userID, req.NewPassword); err != nil {
+		logrus.Errorf("Error resetting password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Password reset successfully",
+	})
+}
+
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.OldPassword == "" {
+		http.Error(w, "old_password is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateNewPassword(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := dbHelper.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := utils.CheckPassword(req.OldPassword, user.Password); err != nil {
+		http.Error(w, "old password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	if err := setPassword(userID, req.NewPassword); err != nil {
+		logrus.Errorf("Error changing password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Password changed successfully, please sign in again",
+	})
+}
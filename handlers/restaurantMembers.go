@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/mailer"
+	"rms/middleware"
+	"rms/models"
+	"strings"
+)
+
+// errLastOwner means a change would leave a restaurant without an owner.
+var errLastOwner = errors.New("a restaurant must keep at least one owner")
+
+// restaurantAccess checks that the caller may act at the active restaurant
+// restaurantID and returns their staff role there. With roles given, the
+// caller's role must be one of them. Holders of restaurant:manage_all act as
+// owners everywhere. ok is false once an error has been written.
+func restaurantAccess(w http.ResponseWriter, r *http.Request, restaurantID uuid.UUID, roles ...string) (string, bool) {
+	return memberAccess(w, r, restaurantID, false, roles)
+}
+
+// archivedRestaurantAccess is restaurantAccess for an archived restaurant.
+func archivedRestaurantAccess(w http.ResponseWriter, r *http.Request, restaurantID uuid.UUID, roles ...string) (string, bool) {
+	return memberAccess(w, r, restaurantID, true, roles)
+}
+
+func memberAccess(w http.ResponseWriter, r *http.Request, restaurantID uuid.UUID, archived bool, roles []string) (string, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+
+	manageAll, ok := canSeeAll(w, r, models.PermRestaurantManageAll)
+	if !ok {
+		return "", false
+	}
+	role := ""
+	if manageAll {
+		exists, err := dbHelper.DoesRestaurantExist(restaurantID)
+		if archived {
+			exists, err = dbHelper.IsRestaurantArchived(restaurantID)
+		}
+		if err != nil {
+			logrus.Errorf("Error checking restaurant existence: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return "", false
+		}
+		if exists {
+			role = models.MemberOwner
+		}
+	} else {
+		var err error
+		role, err = dbHelper.GetMemberRole(restaurantID, userID, archived)
+		if err != nil {
+			logrus.Errorf("Error checking membership of %s: %v", userID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return "", false
+		}
+	}
+
+	// Outsiders cannot tell whether the restaurant exists
+	if role == "" {
+		http.Error(w, "Restaurant not found", http.StatusNotFound)
+		return "", false
+	}
+	if len(roles) > 0 && !containsString(roles, role) {
+		http.Error(w, "Forbidden: your role at this restaurant does not allow this", http.StatusForbidden)
+		return "", false
+	}
+	return role, true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// canManageMember reports whether a member with callerRole may give or take
+// away role. Owners manage everyone; managers only cashiers and chefs.
+func canManageMember(callerRole, role string) bool {
+	switch callerRole {
+	case models.MemberOwner:
+		return true
+	case models.MemberManager:
+		return role == models.MemberChef || role == models.MemberCashier
+	}
+	return false
+}
+
+func pathRestaurantID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	restaurantID, err := uuid.Parse(mux.Vars(r)["restaurant_id"])
+	if err != nil {
+		http.Error(w, "Invalid restaurant ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return restaurantID, true
+}
+
+// pathMember loads the membership named in the URL, answering for the caller
+// if there is none.
+func pathMember(w http.ResponseWriter, r *http.Request, restaurantID uuid.UUID) (*models.RestaurantMember, bool) {
+	userID, ok := pathUserID(w, r)
+	if !ok {
+		return nil, false
+	}
+	member, err := dbHelper.GetRestaurantMember(restaurantID, userID)
+	if err != nil {
+		logrus.Errorf("Error loading member %s: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if member == nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return nil, false
+	}
+	return member, true
+}
+
+// ensureOwnerRemains fails with errLastOwner if the restaurant has no owner left.
+// Call it inside the transaction making the change, after LockRestaurantMembers.
+func ensureOwnerRemains(tx *sqlx.Tx, restaurantID uuid.UUID) error {
+	owners, err := dbHelper.CountRestaurantOwners(tx, restaurantID)
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return errLastOwner
+	}
+	return nil
+}
+
+func ListRestaurantMembers(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := restaurantAccess(w, r, restaurantID); !ok {
+		return
+	}
+
+	members, err := dbHelper.ListRestaurantMembers(restaurantID)
+	if err != nil {
+		logrus.Errorf("Error listing members of %s: %v", restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// AddRestaurantMember invites an existing user to the restaurant's staff and
+// lets them know by email.
+func AddRestaurantMember(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	var req models.AddMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Role = strings.TrimSpace(strings.ToLower(req.Role))
+	if !containsString(models.MemberRoles, req.Role) {
+		http.Error(w, "role must be one of owner, manager, chef or cashier", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" && req.Email == "" {
+		http.Error(w, "user_id or email is required", http.StatusBadRequest)
+		return
+	}
+
+	callerRole, ok := restaurantAccess(w, r, restaurantID, models.RestaurantEditors...)
+	if !ok {
+		return
+	}
+	if !canManageMember(callerRole, req.Role) {
+		http.Error(w, "Forbidden: you cannot give out this role", http.StatusForbidden)
+		return
+	}
+
+	var user *models.User
+	var err error
+	if req.UserID != "" {
+		userID, parseErr := uuid.Parse(req.UserID)
+		if parseErr != nil {
+			http.Error(w, "Invalid user_id format", http.StatusBadRequest)
+			return
+		}
+		user, err = dbHelper.GetUserByID(userID)
+	} else {
+		user, err = dbHelper.GetUserByEmail(strings.TrimSpace(strings.ToLower(req.Email)))
+	}
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	inviterID, _ := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if err := dbHelper.AddRestaurantMember(database.RMS, restaurantID, user.ID, req.Role, &inviterID); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "User is already a member of this restaurant", http.StatusConflict)
+			return
+		}
+		logrus.Errorf("Error adding member %s to %s: %v", user.ID, restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = mailer.Default.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "You have joined a restaurant team",
+		Body: fmt.Sprintf("You were added as %s of restaurant %s. Sign in at %s to get started.\n",
+			req.Role, restaurantID, appBaseURL()),
+	})
+	if err != nil {
+		logrus.Warnf("Failed to notify %s of membership: %v", user.Email, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Member added successfully",
+		"user_id": user.ID,
+		"role":    req.Role,
+	})
+}
+
+// UpdateRestaurantMember changes a member's role. Making someone else owner
+// and then stepping down is how ownership is handed over.
+func UpdateRestaurantMember(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	var req models.UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Role = strings.TrimSpace(strings.ToLower(req.Role))
+	if !containsString(models.MemberRoles, req.Role) {
+		http.Error(w, "role must be one of owner, manager, chef or cashier", http.StatusBadRequest)
+		return
+	}
+
+	callerRole, ok := restaurantAccess(w, r, restaurantID, models.RestaurantEditors...)
+	if !ok {
+		return
+	}
+	member, ok := pathMember(w, r, restaurantID)
+	if !ok {
+		return
+	}
+	if !canManageMember(callerRole, member.Role) || !canManageMember(callerRole, req.Role) {
+		http.Error(w, "Forbidden: you cannot change this member's role", http.StatusForbidden)
+		return
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		if err := dbHelper.LockRestaurantMembers(tx, restaurantID); err != nil {
+			return err
+		}
+		if err := dbHelper.UpdateMemberRole(tx, restaurantID, member.UserID, req.Role); err != nil {
+			return err
+		}
+		return ensureOwnerRemains(tx, restaurantID)
+	})
+	if errors.Is(err, errLastOwner) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error updating member %s of %s: %v", member.UserID, restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Member updated successfully",
+		"role":    req.Role,
+	})
+}
+
+// RemoveRestaurantMember takes someone off the staff. Any member may remove
+// themselves, as long as an owner remains.
+func RemoveRestaurantMember(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	callerRole, ok := restaurantAccess(w, r, restaurantID)
+	if !ok {
+		return
+	}
+	member, ok := pathMember(w, r, restaurantID)
+	if !ok {
+		return
+	}
+	callerID, _ := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if member.UserID != callerID && !canManageMember(callerRole, member.Role) {
+		http.Error(w, "Forbidden: you cannot remove this member", http.StatusForbidden)
+		return
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		if err := dbHelper.LockRestaurantMembers(tx, restaurantID); err != nil {
+			return err
+		}
+		if err := dbHelper.RemoveRestaurantMember(tx, restaurantID, member.UserID); err != nil {
+			return err
+		}
+		return ensureOwnerRemains(tx, restaurantID)
+	})
+	if errors.Is(err, errLastOwner) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error removing member %s of %s: %v", member.UserID, restaurantID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Member removed successfully",
+	})
+}
@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"rms/database"
 	"rms/database/dbHelper"
 	"rms/middleware"
 	"rms/models"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func CreateRestaurant(w http.ResponseWriter, r *http.Request) {
@@ -21,8 +25,19 @@ func CreateRestaurant(w http.ResponseWriter, r *http.Request) {
 	}
 
 	req.RestaurantName = strings.TrimSpace(req.RestaurantName)
-	if req.RestaurantName == "" || req.Lat == 0 || req.Lng == 0 {
-		http.Error(w, "restaurant_name, lat, and lng are required", http.StatusBadRequest)
+	if req.RestaurantName == "" {
+		http.Error(w, "restaurant_name is required", http.StatusBadRequest)
+		return
+	}
+	place, ok := locate(w, req.Lat, req.Lng, req.Address)
+	if !ok {
+		return
+	}
+	req.Lat, req.Lng, req.Address = place.Lat, place.Lng, place.Address
+	if req.DeliveryRadiusKm == 0 {
+		req.DeliveryRadiusKm = defaultDeliveryRadiusKm
+	}
+	if !validDeliveryArea(w, &req.DeliveryRadiusKm, req.DeliveryZone) {
 		return
 	}
 
@@ -33,8 +48,16 @@ func CreateRestaurant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insert restaurant
-	restaurantID, err := dbHelper.CreateRestaurant(req.RestaurantName, req.Lat, req.Lng, userID)
+	// Insert restaurant, with its creator as owner
+	var restaurantID uuid.UUID
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		restaurantID, err = dbHelper.CreateRestaurant(tx, req, userID)
+		if err != nil {
+			return err
+		}
+		return dbHelper.AddRestaurantMember(tx, restaurantID, userID, models.MemberOwner, nil)
+	})
 	if err != nil {
 		logrus.Errorf("CreateRestaurant error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -67,15 +90,8 @@ func CreateDish(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if restaurant exists
-	exists, err := dbHelper.DoesRestaurantExist(restaurantID)
-	if err != nil {
-		logrus.Errorf("Error checking restaurant existence: %v", err)
-		http.Error(w, "Failed to verify restaurant", http.StatusInternalServerError)
-		return
-	}
-	if !exists {
-		http.Error(w, "Restaurant not found", http.StatusNotFound)
+	// Only the restaurant's menu editors may add to it
+	if _, ok := restaurantAccess(w, r, restaurantID, models.MenuEditors...); !ok {
 		return
 	}
 
@@ -91,8 +107,20 @@ func CreateDish(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Price must be greater than 0", http.StatusBadRequest)
 		return
 	}
+	var sectionID *uuid.UUID
+	if req.SectionID != nil {
+		parsed, err := uuid.Parse(*req.SectionID)
+		if err != nil {
+			http.Error(w, "Invalid section_id format", http.StatusBadRequest)
+			return
+		}
+		if !sectionInRestaurant(w, parsed, restaurantID) {
+			return
+		}
+		sectionID = &parsed
+	}
 	// Insert the dish
-	dishID, err := dbHelper.CreateDish(req.DishName, restaurantID, userID, req.Price)
+	dishID, err := dbHelper.CreateDish(req.DishName, strings.TrimSpace(req.Description), restaurantID, userID, req.Price, sectionID)
 	if err != nil {
 		logrus.Errorf("CreateDish error: %v", err)
 		http.Error(w, "Failed to create dish", http.StatusInternalServerError)
@@ -108,29 +136,38 @@ func CreateDish(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetAllRestaurants(w http.ResponseWriter, r *http.Request) {
-	restaurants, err := dbHelper.FetchAllRestaurants()
-	if err != nil {
-		logrus.Errorf("Failed to fetch restaurants: %v", err)
-		http.Error(w, "Failed to fetch restaurants", http.StatusInternalServerError)
+	params, ok := parseListParams(w, r)
+	if !ok {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(restaurants)
+	page, err := dbHelper.FetchAllRestaurants(params)
+	if err == nil {
+		err = fillNextOpensAt(restaurantPointers(page.Items))
+	}
+	writePage(w, page, err, "restaurants")
 }
 
+// GetDishesByRestaurant returns a restaurant's menu: its sections in order,
+// each with its dishes in order, then the dishes outside any section.
 func GetDishesByRestaurant(w http.ResponseWriter, r *http.Request) {
-	//fmt.Println("GetDishesByRestaurant")
-	vars := mux.Vars(r)
-	restaurantIDStr := vars["restaurant_id"]
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
 
-	restaurantID, err := uuid.Parse(restaurantIDStr)
+	exists, err := dbHelper.DoesRestaurantExist(restaurantID)
 	if err != nil {
-		http.Error(w, "Invalid restaurant ID", http.StatusBadRequest)
+		logrus.Errorf("Error checking restaurant existence: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Restaurant not found", http.StatusNotFound)
 		return
 	}
 
-	dishes, err := dbHelper.FetchDishesByRestaurant(restaurantID)
+	menu, err := dbHelper.FetchMenu(restaurantID)
 	if err != nil {
 		logrus.Errorf("Failed to fetch dishes: %v", err)
 		http.Error(w, "Failed to fetch dishes", http.StatusInternalServerError)
@@ -138,78 +175,385 @@ func GetDishesByRestaurant(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(dishes)
+	json.NewEncoder(w).Encode(menu)
 }
 
 func ListRestaurants(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	rolesRaw := ctx.Value(middleware.RolesKey)
 	userIDRaw := ctx.Value(middleware.UserIDKey)
 
-	roles, ok := rolesRaw.([]string)
-	if !ok || len(roles) == 0 {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	userID, ok := userIDRaw.(uuid.UUID)
+	if !ok {
+		http.Error(w, "Invalid user ID", http.StatusUnauthorized)
+		return
+	}
+
+	params, ok := parseListParams(w, r)
+	if !ok {
 		return
 	}
 
+	seeAll, ok := canSeeAll(w, r, models.PermRestaurantListAll)
+	if !ok {
+		return
+	}
+
+	page, err := dbHelper.GetRestaurantsVisibleTo(userID, seeAll, params)
+	if err == nil {
+		err = fillNextOpensAt(restaurantPointers(page.Items))
+	}
+	writePage(w, page, err, "restaurants")
+}
+
+func ListDishes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userIDRaw := ctx.Value(middleware.UserIDKey)
+
 	userID, ok := userIDRaw.(uuid.UUID)
 	if !ok {
 		http.Error(w, "Invalid user ID", http.StatusUnauthorized)
 		return
 	}
 
-	isAdmin := false
-	for _, role := range roles {
-		if strings.ToLower(role) == "admin" {
-			isAdmin = true
-			break
+	includeArchived := false
+	if raw := r.URL.Query().Get("include_archived"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "include_archived must be true or false", http.StatusBadRequest)
+			return
+		}
+		includeArchived = parsed
+	}
+
+	params, ok := parseListParams(w, r, "include_archived")
+	if !ok {
+		return
+	}
+
+	seeAll, ok := canSeeAll(w, r, models.PermDishListAll)
+	if !ok {
+		return
+	}
+
+	page, err := dbHelper.GetDishesVisibleTo(userID, seeAll, includeArchived, params)
+	writePage(w, page, err, "dishes")
+}
+
+// UpdateRestaurant changes the name, location or delivery area of a restaurant.
+func UpdateRestaurant(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	var req models.UpdateRestaurantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Same rules as CreateRestaurant for whatever is being changed
+	if req.RestaurantName == nil && req.Lat == nil && req.Lng == nil && req.Address == nil &&
+		req.DeliveryRadiusKm == nil && req.DeliveryZone == nil && !req.ClearDeliveryZone {
+		http.Error(w, "restaurant_name, lat, lng, address, delivery_radius_km or delivery_zone is required", http.StatusBadRequest)
+		return
+	}
+	if req.DeliveryZone != nil && req.ClearDeliveryZone {
+		http.Error(w, "delivery_zone and clear_delivery_zone cannot be combined", http.StatusBadRequest)
+		return
+	}
+	if req.RestaurantName != nil {
+		name := strings.TrimSpace(*req.RestaurantName)
+		if name == "" {
+			http.Error(w, "restaurant_name cannot be empty", http.StatusBadRequest)
+			return
 		}
+		req.RestaurantName = &name
+	}
+	if !relocate(w, &req.Lat, &req.Lng, &req.Address) {
+		return
+	}
+	if !validDeliveryArea(w, req.DeliveryRadiusKm, req.DeliveryZone) {
+		return
+	}
+
+	if _, ok := restaurantAccess(w, r, restaurantID, models.RestaurantEditors...); !ok {
+		return
 	}
 
-	restaurants, err := dbHelper.GetRestaurantsVisibleTo(userID, isAdmin)
+	restaurant, err := dbHelper.UpdateRestaurant(restaurantID, req)
 	if err != nil {
-		logrus.Errorf("Failed to fetch restaurants: %v", err)
+		logrus.Errorf("UpdateRestaurant error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if restaurant == nil {
+		http.Error(w, "Restaurant not found", http.StatusNotFound)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(restaurants)
+	json.NewEncoder(w).Encode(restaurant)
 }
 
-func ListDishes(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// ArchiveRestaurant archives a restaurant and its dishes. Only owners may.
+func ArchiveRestaurant(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := restaurantAccess(w, r, restaurantID, models.MemberOwner); !ok {
+		return
+	}
 
-	rolesRaw := ctx.Value(middleware.RolesKey)
-	userIDRaw := ctx.Value(middleware.UserIDKey)
+	archived := false
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		archived, err = dbHelper.ArchiveRestaurant(tx, restaurantID, time.Now())
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("ArchiveRestaurant error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !archived {
+		http.Error(w, "Restaurant not found", http.StatusNotFound)
+		return
+	}
 
-	roles, ok := rolesRaw.([]string)
-	if !ok || len(roles) == 0 {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Restaurant archived successfully",
+	})
+}
+
+// RestoreRestaurant undoes ArchiveRestaurant, bringing back the dishes that
+// were archived with the restaurant but not those archived before it.
+func RestoreRestaurant(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := archivedRestaurantAccess(w, r, restaurantID, models.MemberOwner); !ok {
 		return
 	}
 
-	userID, ok := userIDRaw.(uuid.UUID)
+	restored := false
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		restored, err = dbHelper.RestoreRestaurant(tx, restaurantID)
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("RestoreRestaurant error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !restored {
+		http.Error(w, "Restaurant not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Restaurant restored successfully",
+	})
+}
+
+// PurgeRestaurant permanently deletes an archived restaurant with its dishes
+// and staff.
+func PurgeRestaurant(w http.ResponseWriter, r *http.Request) {
+	restaurantID, ok := pathRestaurantID(w, r)
+	if !ok {
+		return
+	}
+
+	purged := false
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		purged, err = dbHelper.PurgeRestaurant(tx, restaurantID)
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("PurgeRestaurant error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !purged {
+		http.Error(w, "Archived restaurant not found; archive it before purging", http.StatusNotFound)
+		return
+	}
+
+	logrus.Infof("restaurant %s purged", restaurantID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Restaurant purged successfully",
+	})
+}
+
+func pathDishID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	dishID, err := uuid.Parse(mux.Vars(r)["dish_id"])
+	if err != nil {
+		http.Error(w, "Invalid dish ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return dishID, true
+}
+
+// dishAccess checks that the caller edits the menu of the restaurant serving
+// a dish that is active, or archived if archived is set, and returns that
+// restaurant.
+func dishAccess(w http.ResponseWriter, r *http.Request, dishID uuid.UUID, archived bool) (uuid.UUID, bool) {
+	restaurantID, err := dbHelper.GetDishRestaurantID(dishID, archived)
+	if err != nil {
+		logrus.Errorf("Error loading dish %s: %v", dishID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return uuid.Nil, false
+	}
+	if restaurantID == uuid.Nil {
+		http.Error(w, "Dish not found", http.StatusNotFound)
+		return uuid.Nil, false
+	}
+	_, ok := restaurantAccess(w, r, restaurantID, models.MenuEditors...)
+	return restaurantID, ok
+}
+
+// UpdateDish changes a dish's name, price, description or section, or moves
+// it to another restaurant whose menu the caller also edits.
+func UpdateDish(w http.ResponseWriter, r *http.Request) {
+	dishID, ok := pathDishID(w, r)
 	if !ok {
-		http.Error(w, "Invalid user ID", http.StatusUnauthorized)
+		return
+	}
+	var req models.UpdateDishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	isAdmin := false
-	for _, role := range roles {
-		if strings.ToLower(role) == "admin" {
-			isAdmin = true
-			break
+	// Same rules as CreateDish for whatever is being changed
+	if req.DishName == nil && req.Price == nil && req.RestaurantID == nil && req.Description == nil && req.SectionID == nil && !req.ClearSection {
+		http.Error(w, "dish_name, price, restaurant_id, description, section_id or clear_section is required", http.StatusBadRequest)
+		return
+	}
+	if req.SectionID != nil && req.ClearSection {
+		http.Error(w, "section_id and clear_section cannot be combined", http.StatusBadRequest)
+		return
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		req.Description = &description
+	}
+	if req.DishName != nil {
+		name := strings.TrimSpace(*req.DishName)
+		if name == "" {
+			http.Error(w, "dish_name cannot be empty", http.StatusBadRequest)
+			return
 		}
+		req.DishName = &name
+	}
+	if req.Price != nil && *req.Price <= 0 {
+		http.Error(w, "Price must be greater than 0", http.StatusBadRequest)
+		return
+	}
+	var targetID *uuid.UUID
+	if req.RestaurantID != nil {
+		parsed, err := uuid.Parse(*req.RestaurantID)
+		if err != nil {
+			http.Error(w, "Invalid restaurant_id format", http.StatusBadRequest)
+			return
+		}
+		targetID = &parsed
+	}
+	var sectionID *uuid.UUID
+	if req.SectionID != nil {
+		parsed, err := uuid.Parse(*req.SectionID)
+		if err != nil {
+			http.Error(w, "Invalid section_id format", http.StatusBadRequest)
+			return
+		}
+		sectionID = &parsed
 	}
 
-	dishes, err := dbHelper.GetDishesVisibleTo(userID, isAdmin)
+	restaurantID, ok := dishAccess(w, r, dishID, false)
+	if !ok {
+		return
+	}
+	if targetID != nil {
+		if _, ok := restaurantAccess(w, r, *targetID, models.MenuEditors...); !ok {
+			return
+		}
+		restaurantID = *targetID
+	}
+	if sectionID != nil && !sectionInRestaurant(w, *sectionID, restaurantID) {
+		return
+	}
+
+	dish, err := dbHelper.UpdateDish(dishID, req.DishName, req.Description, req.Price, targetID, sectionID, req.ClearSection)
 	if err != nil {
-		logrus.Errorf("Failed to fetch dishes: %v", err)
+		logrus.Errorf("UpdateDish error: %v", err)
+		http.Error(w, "Failed to update dish", http.StatusInternalServerError)
+		return
+	}
+	if dish == nil {
+		http.Error(w, "Dish not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dish)
+}
+
+func ArchiveDish(w http.ResponseWriter, r *http.Request) {
+	dishID, ok := pathDishID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := dishAccess(w, r, dishID, false); !ok {
+		return
+	}
+
+	archived, err := dbHelper.ArchiveDish(dishID)
+	if err != nil {
+		logrus.Errorf("ArchiveDish error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !archived {
+		http.Error(w, "Dish not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Dish archived successfully",
+	})
+}
+
+// RestoreDish brings back an archived dish; its restaurant must be active.
+func RestoreDish(w http.ResponseWriter, r *http.Request) {
+	dishID, ok := pathDishID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := dishAccess(w, r, dishID, true); !ok {
+		return
+	}
+
+	restored, err := dbHelper.RestoreDish(dishID)
+	if err != nil {
+		logrus.Errorf("RestoreDish error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if !restored {
+		http.Error(w, "Dish not found", http.StatusNotFound)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(dishes)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Dish restored successfully",
+	})
 }
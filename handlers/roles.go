@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/middleware"
+	"rms/models"
+	"rms/utils"
+	"strings"
+)
+
+// canSeeAll reports whether the caller holds perm, which widens a listing from
+// their own records to everyone's. ok is false once an error has been written.
+func canSeeAll(w http.ResponseWriter, r *http.Request, perm string) (bool, bool) {
+	allowed, err := middleware.HasPermission(r.Context(), perm)
+	if err != nil {
+		logrus.Errorf("Failed to check permission %s: %v", perm, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false, false
+	}
+	return allowed, true
+}
+
+// validPermissions normalises names and checks they all exist, answering for
+// the caller if they do not.
+func validPermissions(w http.ResponseWriter, names []string) ([]string, bool) {
+	perms := uniqueLower(names)
+	if len(perms) == 0 {
+		http.Error(w, "permissions are required", http.StatusBadRequest)
+		return nil, false
+	}
+	count, err := dbHelper.CountPermissions(perms)
+	if err != nil {
+		logrus.Errorf("Error checking permissions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if count != len(perms) {
+		http.Error(w, "unknown permission in permissions", http.StatusBadRequest)
+		return nil, false
+	}
+	return perms, true
+}
+
+// pathRole loads the role named in the URL, answering for the caller if there is none.
+func pathRole(w http.ResponseWriter, r *http.Request) (*models.Role, bool) {
+	role, err := dbHelper.GetRoleByName(strings.ToLower(mux.Vars(r)["role"]))
+	if err != nil {
+		logrus.Errorf("Error loading role: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if role == nil {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return nil, false
+	}
+	return role, true
+}
+
+func ListPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := dbHelper.ListPermissions()
+	if err != nil {
+		logrus.Errorf("Error listing permissions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(permissions)
+}
+
+func ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := dbHelper.ListRoles()
+	if err != nil {
+		logrus.Errorf("Error listing roles: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+func CreateRole(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(strings.ToLower(req.Name))
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	perms, ok := validPermissions(w, req.Permissions)
+	if !ok {
+		return
+	}
+
+	roleID := uuid.New()
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		if err := dbHelper.CreateRole(tx, roleID, name); err != nil {
+			return err
+		}
+		return dbHelper.GrantPermissions(tx, roleID, perms)
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "Role already exists", http.StatusConflict)
+			return
+		}
+		logrus.Errorf("Error creating role %s: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	middleware.InvalidatePermissions()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.Role{ID: roleID, Name: name, Permissions: perms})
+}
+
+func DeleteRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := pathRole(w, r)
+	if !ok {
+		return
+	}
+	if role.IsSystem {
+		http.Error(w, "Built-in roles cannot be deleted", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := dbHelper.DeleteRole(role.ID)
+	if err != nil {
+		logrus.Errorf("Error deleting role %s: %v", role.Name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Role is still assigned to users or API keys", http.StatusConflict)
+		return
+	}
+	middleware.InvalidatePermissions()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Role deleted successfully",
+	})
+}
+
+func GrantRolePermissions(w http.ResponseWriter, r *http.Request) {
+	var req models.GrantPermissionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	role, ok := pathRole(w, r)
+	if !ok {
+		return
+	}
+	if role.Name == models.SuperRole {
+		http.Error(w, "The admin role always has every permission", http.StatusBadRequest)
+		return
+	}
+	perms, ok := validPermissions(w, req.Permissions)
+	if !ok {
+		return
+	}
+
+	if err := dbHelper.GrantPermissions(database.RMS, role.ID, perms); err != nil {
+		logrus.Errorf("Error granting permissions to %s: %v", role.Name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	middleware.InvalidatePermissions()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Permissions granted successfully",
+	})
+}
+
+func RevokeRolePermission(w http.ResponseWriter, r *http.Request) {
+	role, ok := pathRole(w, r)
+	if !ok {
+		return
+	}
+	if role.Name == models.SuperRole {
+		http.Error(w, "The admin role always has every permission", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := dbHelper.RevokePermission(role.ID, strings.ToLower(mux.Vars(r)["permission"]))
+	if err != nil {
+		logrus.Errorf("Error revoking permission from %s: %v", role.Name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "Role does not have this permission", http.StatusNotFound)
+		return
+	}
+	middleware.InvalidatePermissions()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Permission revoked successfully",
+	})
+}
+
+// AssignUserRole gives a user another role. It shows up in their access
+// tokens from their next sign-in or refresh.
+func AssignUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	var req models.AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	roleName := strings.TrimSpace(strings.ToLower(req.Role))
+	if roleName == "" {
+		http.Error(w, "role is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := dbHelper.GetUserByID(userID); err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	role, err := dbHelper.GetRoleByName(roleName)
+	if err != nil {
+		logrus.Errorf("Error loading role %s: %v", roleName, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role == nil {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+
+	if err := dbHelper.AssignRoleToUser(database.RMS, userID, role.Name); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "User already has this role", http.StatusConflict)
+			return
+		}
+		logrus.Errorf("Error assigning role %s to %s: %v", role.Name, userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Role assigned successfully",
+	})
+}
+
+// RemoveUserRole takes a role away and signs the user out everywhere, since
+// their current access tokens still carry it.
+func RemoveUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	role, ok := pathRole(w, r)
+	if !ok {
+		return
+	}
+	if callerID, _ := r.Context().Value(middleware.UserIDKey).(uuid.UUID); callerID == userID && role.Name == models.SuperRole {
+		http.Error(w, "You cannot remove your own admin role", http.StatusBadRequest)
+		return
+	}
+
+	removed := false
+	var familyIDs []uuid.UUID
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		removed, err = dbHelper.RemoveRoleFromUser(tx, userID, role.ID)
+		if err != nil || !removed {
+			return err
+		}
+		familyIDs, err = dbHelper.RevokeUserSessions(tx, userID)
+		return err
+	})
+	if err == nil {
+		err = utils.DenySessions(familyIDs...)
+	}
+	if err != nil {
+		logrus.Errorf("Error removing role %s from %s: %v", role.Name, userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "User does not have this role", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Role removed successfully",
+	})
+}
@@ -2,32 +2,50 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 	"net/http"
+	"rms/database"
 	"rms/database/dbHelper"
+	"rms/middleware"
 	"rms/models"
 	"rms/utils"
-	"strings"
-	"time"
 )
 
-func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
-	//get refresh token
-	tokenStr := r.Header.Get("Authorization")
-	if tokenStr == "" || !strings.HasPrefix(tokenStr, "Bearer ") {
-		http.Error(w, "missing or invalid Authorization header", http.StatusUnauthorized)
+// writeTokenResponse starts a new session for the user and responds with its
+// access/refresh token pair.
+func writeTokenResponse(w http.ResponseWriter, r *http.Request, userID uuid.UUID, roles []string, message string, status int) {
+	// Refresh token
+	refreshToken, sessionID, err := utils.CreateRefreshToken(userID, utils.ClientInfoFromRequest(r))
+	if err != nil {
+		http.Error(w, "failed to create refresh token", http.StatusInternalServerError)
 		return
 	}
-	refreshToken := strings.TrimPrefix(tokenStr, "Bearer ")
 
-	// Check if refresh token exists and is valid
-	session, err := dbHelper.GetSessionByToken(refreshToken)
+	// Generate JWT
+	accessToken, err := utils.GenerateJWT(userID.String(), sessionID, roles)
 	if err != nil {
-		http.Error(w, "invalid or expired refresh token", http.StatusUnauthorized)
+		http.Error(w, "failed to generate access token", http.StatusInternalServerError)
 		return
 	}
-	if session.ExpiresAt.Before(time.Now()) {
-		_ = dbHelper.DeleteSession(refreshToken)
-		http.Error(w, "refresh token expired", http.StatusUnauthorized)
+
+	res := models.Response{
+		Message:      message,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Session of the presented refresh token, checked by SessionValidationMiddleware
+	session, ok := r.Context().Value(middleware.SessionKey).(*models.Session)
+	if !ok {
+		http.Error(w, "invalid or expired refresh token", http.StatusUnauthorized)
 		return
 	}
 
@@ -38,22 +56,26 @@ func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate new access token
-	accessToken, err := utils.GenerateJWT(session.UserID.String(), roles)
-	if err != nil {
-		http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+	// Rotate: retire this token and issue its successor in the same family
+	newRefreshToken, err := utils.RotateRefreshToken(session, utils.ClientInfoFromRequest(r))
+	if errors.Is(err, utils.ErrRefreshTokenReused) {
+		// Lost a race with another use of the same token
+		middleware.RevokeReusedSessionFamily(r, session)
+		http.Error(w, "invalid or expired refresh token", http.StatusUnauthorized)
 		return
 	}
-
-	// Generate new refresh token
-	newRefreshToken, err := utils.CreateRefreshToken(session.UserID)
 	if err != nil {
+		logrus.Errorf("Failed to rotate refresh token: %v", err)
 		http.Error(w, "Failed to generate refresh token", http.StatusInternalServerError)
 		return
 	}
 
-	// Remove old session
-	_ = dbHelper.DeleteSession(refreshToken)
+	// Generate new access token
+	accessToken, err := utils.GenerateJWT(session.UserID.String(), session.FamilyID, roles)
+	if err != nil {
+		http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+		return
+	}
 
 	response := models.Response{
 		Message:      "Refresh token generated",
@@ -63,3 +85,121 @@ func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
 }
+
+// writeUserSessions lists the user's sessions, flagging currentID as the caller's own.
+func writeUserSessions(w http.ResponseWriter, userID, currentID uuid.UUID) {
+	sessions, err := dbHelper.ListActiveSessions(userID)
+	if err != nil {
+		logrus.Errorf("Failed to list sessions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+func revokeUserSession(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	sessionID, err := uuid.Parse(mux.Vars(r)["session_id"])
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	found, err := dbHelper.RevokeUserSessionFamily(userID, sessionID)
+	if err != nil {
+		logrus.Errorf("Failed to revoke session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err := utils.DenySessions(sessionID); err != nil {
+		logrus.Errorf("Failed to deny session %s: %v", sessionID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Session revoked",
+	})
+}
+
+func revokeAllUserSessions(w http.ResponseWriter, userID uuid.UUID) {
+	familyIDs, err := dbHelper.RevokeUserSessions(database.RMS, userID)
+	if err == nil {
+		err = utils.DenySessions(familyIDs...)
+	}
+	if err != nil {
+		logrus.Errorf("Failed to revoke sessions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Signed out of all sessions",
+	})
+}
+
+// pathUserID parses the {user_id} route variable of the admin session routes.
+func pathUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(mux.Vars(r)["user_id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func ListMySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentID, _ := r.Context().Value(middleware.SessionIDKey).(uuid.UUID)
+	writeUserSessions(w, userID, currentID)
+}
+
+func RevokeMySession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	revokeUserSession(w, r, userID)
+}
+
+func RevokeAllMySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	revokeAllUserSessions(w, userID)
+}
+
+func ListUserSessions(w http.ResponseWriter, r *http.Request) {
+	if userID, ok := pathUserID(w, r); ok {
+		writeUserSessions(w, userID, uuid.Nil)
+	}
+}
+
+func RevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	if userID, ok := pathUserID(w, r); ok {
+		revokeUserSession(w, r, userID)
+	}
+}
+
+func RevokeAllUserSessions(w http.ResponseWriter, r *http.Request) {
+	if userID, ok := pathUserID(w, r); ok {
+		revokeAllUserSessions(w, userID)
+	}
+}
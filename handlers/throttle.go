@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"math"
+	"net/http"
+	"rms/database/dbHelper"
+	"rms/middleware"
+	"rms/models"
+	"rms/utils"
+	"strconv"
+	"time"
+)
+
+func accountThrottleKey(email string) string {
+	return "account:" + email
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginDelay is how long to wait after the given number of consecutive
+// failures: LOGIN_DELAY_BASE, doubling each time, capped at LOGIN_DELAY_MAX.
+func loginDelay(failures int) time.Duration {
+	base := utils.EnvDuration("LOGIN_DELAY_BASE", time.Second)
+	max := utils.EnvDuration("LOGIN_DELAY_MAX", 30*time.Second)
+	if failures <= 0 {
+		return 0
+	}
+	delay := float64(base) * math.Pow(2, float64(failures-1))
+	if delay > float64(max) {
+		return max
+	}
+	return time.Duration(delay)
+}
+
+func writeTooManyAttempts(w http.ResponseWriter, message string, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
+
+// checkLoginAllowed answers 429 and returns false while the account or the
+// client IP is locked out or still inside its progressive delay.
+func checkLoginAllowed(w http.ResponseWriter, r *http.Request, email string) bool {
+	now := time.Now()
+	for _, key := range []string{accountThrottleKey(email), ipThrottleKey(utils.ClientIP(r))} {
+		throttle, err := dbHelper.GetLoginThrottle(key)
+		if err != nil {
+			logrus.Errorf("Error loading login throttle %s: %v", key, err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return false
+		}
+		if throttle == nil {
+			continue
+		}
+		if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+			writeTooManyAttempts(w, "too many failed sign-in attempts, try again later", throttle.LockedUntil.Sub(now))
+			return false
+		}
+		if wait := throttle.LastFailureAt.Add(loginDelay(throttle.Failures)).Sub(now); wait > 0 {
+			writeTooManyAttempts(w, "too many sign-in attempts, slow down", wait)
+			return false
+		}
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed password or 2FA code against both the
+// account and the client IP, locking whichever crosses its threshold.
+func recordLoginFailure(r *http.Request, email string, userID uuid.UUID) {
+	now := time.Now()
+	windowStart := now.Add(-utils.EnvDuration("LOGIN_FAILURE_WINDOW", time.Hour))
+	ip := utils.ClientIP(r)
+
+	thresholds := map[string]int{
+		accountThrottleKey(email): utils.EnvInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		ipThrottleKey(ip):         utils.EnvInt("LOGIN_IP_LOCKOUT_THRESHOLD", 20),
+	}
+	for key, threshold := range thresholds {
+		throttle, err := dbHelper.RecordLoginFailure(key, now, windowStart)
+		if err != nil {
+			logrus.Errorf("Error recording login failure for %s: %v", key, err)
+			continue
+		}
+		if throttle.Failures < threshold || (throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil)) {
+			continue
+		}
+
+		until := now.Add(utils.EnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute))
+		if err := dbHelper.LockLoginThrottle(key, until); err != nil {
+			logrus.Errorf("Error locking %s: %v", key, err)
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"key":      key,
+			"failures": throttle.Failures,
+			"ip":       ip,
+		}).Warn("security event: sign-in locked out")
+
+		event := models.SecurityEvent{
+			EventType: models.EventLoginLockout,
+			IPAddress: ip,
+			Details:   fmt.Sprintf("%s locked until %s after %d failures", key, until.UTC().Format(time.RFC3339), throttle.Failures),
+		}
+		if key == accountThrottleKey(email) {
+			event.UserID = userID
+		}
+		if err := dbHelper.RecordSecurityEvent(event); err != nil {
+			logrus.Errorf("failed to record security event: %v", err)
+		}
+	}
+}
+
+// clearLoginFailures resets the account counter after a complete sign-in.
+func clearLoginFailures(email string) {
+	if _, err := dbHelper.ClearLoginThrottle(accountThrottleKey(email)); err != nil {
+		logrus.Errorf("Error clearing login throttle for %s: %v", email, err)
+	}
+}
+
+// UnlockUser lifts a sign-in lockout on an account, and on ?ip= if given.
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	adminID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := dbHelper.GetUserByID(targetID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	keys := []string{accountThrottleKey(user.Email)}
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		keys = append(keys, ipThrottleKey(ip))
+	}
+	for _, key := range keys {
+		if _, err := dbHelper.ClearLoginThrottle(key); err != nil {
+			logrus.Errorf("Error clearing login throttle %s: %v", key, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		event := models.SecurityEvent{
+			EventType: models.EventLoginUnlock,
+			UserID:    targetID,
+			IPAddress: utils.ClientIP(r),
+			Details:   fmt.Sprintf("%s unlocked by admin %s", key, adminID),
+		}
+		if err := dbHelper.RecordSecurityEvent(event); err != nil {
+			logrus.Errorf("failed to record security event: %v", err)
+		}
+	}
+	logrus.Infof("admin %s unlocked sign-in for user %s", adminID, targetID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "User unlocked successfully",
+	})
+}
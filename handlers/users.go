@@ -3,11 +3,13 @@ package handlers
 import (
 	"encoding/json"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 	"net/http"
 	"rms/middleware"
 	"strings"
-	"time"
 
+	"rms/database"
 	"rms/database/dbHelper"
 	"rms/models"
 	"rms/utils"
@@ -15,88 +17,86 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-//func RegisterHandler(w http.ResponseWriter, r *http.Request) {
-//	var req models.RegisterRequest
-//	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-//		http.Error(w, "invalid request body", http.StatusBadRequest)
-//		return
-//	}
-//
-//	// Check if user already exists
-//	exists, err := dbHelper.IsEmailAlreadyRegistered(req.Email)
-//	if err != nil {
-//		http.Error(w, "internal server error", http.StatusInternalServerError)
-//		return
-//	}
-//	if exists {
-//		http.Error(w, "email already in use", http.StatusConflict)
-//		return
-//	}
-//
-//	// Hash password
-//	hashedPassword, err := utils.HashPassword(req.Password)
-//	if err != nil {
-//		http.Error(w, "failed to hash password", http.StatusInternalServerError)
-//		return
-//	}
-//
-//	// Create user
-//	userID := uuid.New()
-//	err = dbHelper.CreateUser(userID, req.Username, req.Email, hashedPassword)
-//	if err != nil {
-//		http.Error(w, "failed to create user", http.StatusInternalServerError)
-//		return
-//	}
-//
-//	// Assign roles
-//	if len(req.Roles) == 0 {
-//		req.Roles = []string{"user"}
-//	}
-//	for _, roleName := range req.Roles {
-//		err := dbHelper.AssignRoleToUser(userID, roleName)
-//		if err != nil {
-//			logrus.Warnf("role assignment failed: %s", err.Error())
-//		}
-//	}
-//
-//	// Add addresses
-//	if len(req.Addresses) > 0 {
-//		for _, addr := range req.Addresses {
-//			err := dbHelper.InsertAddress(userID, addr)
-//			if err != nil {
-//				logrus.Warnf("Failed to insert address for user %s: %v", userID, err)
-//			}
-//		}
-//	} else {
-//		logrus.Infof("No address provided for user %s", userID)
-//	}
-//
-//	//create access token
-//	accessToken, err := utils.GenerateJWT(userID.String(), req.Roles)
-//	if err != nil {
-//		http.Error(w, "failed to generate access token", http.StatusInternalServerError)
-//		return
-//	}
-//
-//	// Create refresh token
-//	refreshToken, err := utils.CreateRefreshToken(userID)
-//	if err != nil {
-//		http.Error(w, "failed to create session", http.StatusInternalServerError)
-//		return
-//	}
-//
-//	//create response
-//	resp := models.Response{
-//		Message:      "User registered successfully",
-//		AccessToken:  accessToken,
-//		RefreshToken: refreshToken,
-//	}
-//	//send response
-//	w.WriteHeader(http.StatusCreated)
-//	w.Header().Set("Content-Type", "application/json")
-//	json.NewEncoder(w).Encode(resp)
-//
-//}
+func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	if req.Username == "" || req.Email == "" || req.Password == "" {
+		http.Error(w, "username, email and password are required", http.StatusBadRequest)
+		return
+	}
+
+	// Self-service accounts are always plain users, whatever the client asks for
+	for _, roleName := range req.Roles {
+		if !strings.EqualFold(roleName, "user") {
+			logrus.Warnf("signup for %s requested role %q, ignoring", req.Email, roleName)
+		}
+	}
+	roles := []string{"user"}
+	for _, addr := range req.Addresses {
+		if !validAddressPoint(w, addr.Lat, addr.Lng) {
+			return
+		}
+	}
+
+	// Check if user already exists
+	exists, err := dbHelper.IsEmailAlreadyRegistered(req.Email)
+	if err != nil {
+		logrus.Errorf("Error checking email: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		http.Error(w, "email already in use", http.StatusConflict)
+		return
+	}
+
+	// Hash password
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	// Create user, role and addresses together
+	userID := uuid.New()
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		if err := dbHelper.CreateUser(tx, userID, req.Username, req.Email, hashedPassword); err != nil {
+			return err
+		}
+		for _, roleName := range roles {
+			if err := dbHelper.AssignRoleToUser(tx, userID, roleName); err != nil {
+				return err
+			}
+		}
+		for _, addr := range req.Addresses {
+			if err := dbHelper.InsertAddress(tx, userID, addr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "email already in use", http.StatusConflict)
+			return
+		}
+		logrus.Errorf("Error registering user: %v", err)
+		http.Error(w, "failed to create user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := sendVerificationEmail(userID, req.Email); err != nil {
+		logrus.Warnf("Failed to send verification email to %s: %v", req.Email, err)
+	}
+
+	writeTokenResponse(w, r, userID, roles, "User registered successfully", http.StatusCreated)
+}
 
 func CreateSubadmin(w http.ResponseWriter, r *http.Request) {
 	// Parse body
@@ -204,9 +204,16 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse early while the account or client is locked out
+	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	if !checkLoginAllowed(w, r, req.Email) {
+		return
+	}
+
 	// Get user
 	user, err := dbHelper.GetUserByEmail(req.Email)
 	if err != nil {
+		recordLoginFailure(r, req.Email, uuid.Nil)
 		http.Error(w, "invalid email or password", http.StatusUnauthorized)
 		return
 	}
@@ -214,82 +221,61 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Check password
 	if err := utils.CheckPassword(req.Password, user.Password); err != nil {
 		//fmt.Println("LoginHandler :%w", err)
+		recordLoginFailure(r, req.Email, user.ID)
 		http.Error(w, "invalid email or password", http.StatusUnauthorized)
 		return
 	}
 
-	// Get roles
-	roles, err := dbHelper.GetUserRoles(user.ID)
-	if err != nil {
-		http.Error(w, "failed to fetch roles", http.StatusInternalServerError)
+	// Optionally refuse accounts that never confirmed their email
+	if utils.EnvBool("REQUIRE_EMAIL_VERIFICATION") && user.VerifiedAt == nil {
+		http.Error(w, "email address not verified", http.StatusForbidden)
 		return
 	}
 
-	// Generate JWT
-	accessToken, err := utils.GenerateJWT(user.ID.String(), roles)
+	// Second factor, when enabled: hand out a challenge instead of tokens
+	mfaEnabled, err := dbHelper.IsMFAEnabled(user.ID)
 	if err != nil {
-		http.Error(w, "failed to generate access token", http.StatusInternalServerError)
+		logrus.Errorf("Error checking 2FA for %s: %v", user.ID, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
-
-	// Refresh token
-	refreshToken, err := utils.CreateRefreshToken(user.ID)
-	if err != nil {
-		http.Error(w, "failed to create refresh token", http.StatusInternalServerError)
+	if mfaEnabled {
+		writeMFAChallenge(w, user.ID)
 		return
 	}
 
-	//  Respond
-	res := models.Response{
-		Message:      "User logged in Successfully",
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+	// Get roles
+	roles, err := dbHelper.GetUserRoles(user.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch roles", http.StatusInternalServerError)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(res)
+	clearLoginFailures(req.Email)
+	writeTokenResponse(w, r, user.ID, roles, "User logged in Successfully", http.StatusOK)
 }
 
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	//get refresh token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	tokenParts := strings.Split(authHeader, " ")
-	if len(tokenParts) != 2 || strings.ToLower(tokenParts[0]) != "bearer" {
-		http.Error(w, "invalid authorization header format", http.StatusUnauthorized)
-		return
-	}
-
-	refreshToken := tokenParts[1]
-
-	// Check if session exists
-	session, err := dbHelper.GetSessionByToken(refreshToken)
-	if err != nil {
-		logrus.Errorf("error fetching session for token %s: %v", refreshToken, err)
+	// Session of the presented refresh token, checked by SessionValidationMiddleware
+	session, ok := r.Context().Value(middleware.SessionKey).(*models.Session)
+	if !ok {
 		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
 		return
 	}
 
-	// Check if session is expired
-	if session.ExpiresAt.Before(time.Now()) {
-		logrus.Warnf("token expired: %s", refreshToken)
-		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
-		return
+	// Revoke the whole family so no rotation of this sign-in stays usable,
+	// including access tokens already issued for it
+	err := dbHelper.RevokeSessionFamily(session.FamilyID)
+	if err == nil {
+		err = utils.DenySessions(session.FamilyID)
 	}
-
-	// Delete session (logout)
-	err = dbHelper.DeleteSession(refreshToken)
 	if err != nil {
-		logrus.Errorf("failed to delete session: %v", err)
+		logrus.Errorf("failed to revoke session: %v", err)
 		http.Error(w, "failed to logout", http.StatusInternalServerError)
 		return
 	}
 
-	logrus.Infof("logout successful for token: %s", refreshToken)
+	logrus.Infof("logout successful for session: %s", session.FamilyID)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Logout successful"))
 }
@@ -301,6 +287,12 @@ func AddUserAddress(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	req.Label = strings.TrimSpace(req.Label)
+	place, ok := locate(w, req.Lat, req.Lng, req.Address)
+	if !ok {
+		return
+	}
+	req.Lat, req.Lng, req.Address = place.Lat, place.Lng, place.Address
 
 	// Get userID from context (set by AuthMiddleware)
 	userID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
@@ -310,7 +302,7 @@ func AddUserAddress(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save to DB
-	addressID, err := dbHelper.InsertUserAddress(userID, req.Label, req.Lat, req.Lng)
+	addressID, err := dbHelper.InsertUserAddress(userID, req)
 	if err != nil {
 		logrus.Errorf("Failed to insert address: %v", err)
 		http.Error(w, "Failed to insert address", http.StatusInternalServerError)
@@ -328,15 +320,13 @@ func AddUserAddress(w http.ResponseWriter, r *http.Request) {
 // handlers/users.go
 
 func ListSubadmins(w http.ResponseWriter, r *http.Request) {
-	subadmins, err := dbHelper.GetUsersByRole("subadmin")
-	if err != nil {
-		logrus.Errorf("Error fetching subadmins: %v", err)
-		http.Error(w, "Failed to fetch subadmins", http.StatusInternalServerError)
+	params, ok := parseListParams(w, r)
+	if !ok {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(subadmins)
+	page, err := dbHelper.GetUsersByRole("subadmin", params)
+	writePage(w, page, err, "subadmins")
 }
 
 // handlers/users.go
@@ -344,36 +334,71 @@ func ListSubadmins(w http.ResponseWriter, r *http.Request) {
 func ListUsers(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	rolesRaw := ctx.Value(middleware.RolesKey)
 	userIDRaw := ctx.Value(middleware.UserIDKey)
 
-	roles, ok := rolesRaw.([]string)
-	if !ok || len(roles) == 0 {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	userID, ok := userIDRaw.(uuid.UUID)
+	if !ok {
+		http.Error(w, "Invalid user ID in context", http.StatusUnauthorized)
 		return
 	}
 
-	userID, ok := userIDRaw.(uuid.UUID)
+	params, ok := parseListParams(w, r)
 	if !ok {
-		http.Error(w, "Invalid user ID in context", http.StatusUnauthorized)
 		return
 	}
 
-	isAdmin := false
-	for _, role := range roles {
-		if strings.ToLower(role) == "admin" {
-			isAdmin = true
-			break
-		}
+	seeAll, ok := canSeeAll(w, r, models.PermUserListAll)
+	if !ok {
+		return
 	}
 
-	users, err := dbHelper.GetUsersVisibleTo(userID, isAdmin)
+	page, err := dbHelper.GetUsersVisibleTo(userID, seeAll, params)
+	writePage(w, page, err, "users")
+}
+
+func ArchiveUser(w http.ResponseWriter, r *http.Request) {
+	targetID, err := uuid.Parse(mux.Vars(r)["user_id"])
 	if err != nil {
-		logrus.Errorf("Failed to get users: %v", err)
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	adminID, ok := r.Context().Value(middleware.UserIDKey).(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if adminID == targetID {
+		http.Error(w, "You cannot archive your own account", http.StatusBadRequest)
+		return
+	}
+
+	// Archive and sign out everywhere together
+	archived := false
+	var familyIDs []uuid.UUID
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		archived, err = dbHelper.ArchiveUser(tx, targetID)
+		if err != nil || !archived {
+			return err
+		}
+		familyIDs, err = dbHelper.RevokeUserSessions(tx, targetID)
+		return err
+	})
+	if err == nil {
+		err = utils.DenySessions(familyIDs...)
+	}
+	if err != nil {
+		logrus.Errorf("Error archiving user %s: %v", targetID, err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if !archived {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "User archived successfully",
+	})
 }
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"rms/database"
+	"rms/database/dbHelper"
+	"rms/mailer"
+	"rms/models"
+	"rms/utils"
+	"strings"
+	"time"
+)
+
+// appBaseURL is where links in outgoing mail point to.
+func appBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	return "http://localhost:8081"
+}
+
+// issueMailedToken records a single-use token for userID and returns its signed form.
+func issueMailedToken(userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	tokenID := uuid.New()
+	if err := dbHelper.CreateUserToken(tokenID, userID, purpose, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return utils.GeneratePurposeToken(tokenID, userID, purpose, ttl)
+}
+
+func sendVerificationEmail(userID uuid.UUID, email string) error {
+	ttl := utils.EnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	token, err := issueMailedToken(userID, models.PurposeEmailVerification, ttl)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Default.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm your email address by opening the link below within %s:\n\n%s/verify-email?token=%s\n\nVerification token: %s\n",
+			ttl, appBaseURL(), token, token),
+	})
+}
+
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := utils.ParsePurposeToken(req.Token, models.PurposeEmailVerification)
+	if err != nil {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	consumed := false
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		consumed, err = dbHelper.ConsumeUserToken(tx, tokenID, userID, models.PurposeEmailVerification)
+		if err != nil || !consumed {
+			return err
+		}
+		return dbHelper.MarkUserVerified(tx, userID)
+	})
+	if err != nil {
+		logrus.Errorf("Error verifying email: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !consumed {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Email verified successfully",
+	})
+}
+
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	if req.Email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	// Same answer whether or not the account exists, so emails can't be probed
+	user, err := dbHelper.GetUserByEmail(req.Email)
+	if err == nil && user.VerifiedAt == nil {
+		if err := sendVerificationEmail(user.ID, user.Email); err != nil {
+			logrus.Errorf("Error sending verification email to %s: %v", user.Email, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "If the account exists and is unverified, a verification email has been sent",
+	})
+}
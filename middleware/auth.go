@@ -11,6 +11,12 @@ import (
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Services and kiosks authenticate with an API key instead of a JWT
+		if apiKey := apiKeyFromRequest(r); apiKey != "" {
+			serveWithAPIKey(w, r, next, apiKey)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			logrus.Info("no Authorization header")
@@ -40,9 +46,37 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Logout, password change and archival revoke tokens before they expire
+		revoked, err := utils.IsAccessTokenRevoked(claims)
+		if err != nil {
+			logrus.Errorf("failed to check token revocation: %v", err)
+			http.Error(w, "unable to verify token", http.StatusServiceUnavailable)
+			return
+		}
+		if revoked {
+			logrus.Info("revoked token")
+			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+
 		// Inject into request context
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
 		ctx = context.WithValue(ctx, RolesKey, claims.Roles)
+		if sessionID, err := uuid.Parse(claims.SessionID); err == nil {
+			ctx = context.WithValue(ctx, SessionIDKey, sessionID)
+		}
+
+		// An admin acting as this user: keep both identities on record
+		if claims.Act != nil {
+			actorID, err := uuid.Parse(claims.Act.Subject)
+			if err != nil {
+				logrus.Info("invalid actor id")
+				http.Error(w, "invalid actor in token", http.StatusUnauthorized)
+				return
+			}
+			ctx = context.WithValue(ctx, ActorIDKey, actorID)
+			auditImpersonatedRequest(r, userID, actorID)
+		}
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -5,4 +5,12 @@ type contextKey string
 const (
 	UserIDKey contextKey = "userID"
 	RolesKey  contextKey = "roles"
+	// SessionIDKey holds the uuid.UUID session (token family) of the access token
+	SessionIDKey contextKey = "sessionID"
+	// APIKeyIDKey holds the uuid.UUID of the API key a request authenticated with
+	APIKeyIDKey contextKey = "apiKeyID"
+	// SessionKey holds the *models.Session of the presented refresh token
+	SessionKey contextKey = "session"
+	// ActorIDKey holds the uuid.UUID of the admin behind an impersonation token
+	ActorIDKey contextKey = "actorID"
 )
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database/dbHelper"
+	"rms/models"
+	"rms/utils"
+)
+
+// auditImpersonatedRequest logs and records a request made with an
+// impersonation token, naming both the user and the admin behind it.
+func auditImpersonatedRequest(r *http.Request, userID, actorID uuid.UUID) {
+	ip := utils.ClientIP(r)
+	logrus.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"actor_id": actorID,
+		"method":   r.Method,
+		"path":     r.URL.Path,
+		"ip":       ip,
+	}).Info("impersonated request")
+
+	event := models.SecurityEvent{
+		EventType: models.EventImpersonatedCall,
+		UserID:    userID,
+		IPAddress: ip,
+		Details:   fmt.Sprintf("admin %s: %s %s", actorID, r.Method, r.URL.RequestURI()),
+	}
+	if err := dbHelper.RecordSecurityEvent(event); err != nil {
+		logrus.Errorf("failed to record security event: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"rms/database/dbHelper"
+	"strings"
+)
+
+// MFARequired reports whether any of roles is listed in MFA_REQUIRED_ROLES
+// (comma separated, e.g. "admin,subadmin").
+func MFARequired(roles []string) bool {
+	for _, required := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+		required = strings.TrimSpace(required)
+		if required == "" {
+			continue
+		}
+		for _, role := range roles {
+			if strings.EqualFold(role, required) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequireMFAEnrollment blocks users whose role mandates 2FA until they have
+// enrolled. They can still sign in and reach /me/2fa to do so.
+func RequireMFAEnrollment(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// API keys are minted by admins and never sign in interactively
+		roles, _ := r.Context().Value(RolesKey).([]string)
+		if !MFARequired(roles) || r.Context().Value(APIKeyIDKey) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, ok := r.Context().Value(UserIDKey).(uuid.UUID)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		enabled, err := dbHelper.IsMFAEnabled(userID)
+		if err != nil {
+			logrus.Errorf("failed to check 2FA for %s: %v", userID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !enabled {
+			logrus.Infof("user %s must enroll in 2FA", userID)
+			http.Error(w, "Forbidden: two-factor authentication is required for your role, enroll at /me/2fa/enroll", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
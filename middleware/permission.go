@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"rms/database/dbHelper"
+	"rms/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// permissionCacheTTL bounds how long another instance's grant changes take to
+// show up here; changes made through this instance apply at once.
+const permissionCacheTTL = 30 * time.Second
+
+var permissionCache struct {
+	sync.Mutex
+	byRole   map[string]map[string]bool
+	loadedAt time.Time
+}
+
+// InvalidatePermissions drops the cached role→permission mapping.
+func InvalidatePermissions() {
+	permissionCache.Lock()
+	permissionCache.byRole = nil
+	permissionCache.Unlock()
+}
+
+func rolePermissions() (map[string]map[string]bool, error) {
+	permissionCache.Lock()
+	defer permissionCache.Unlock()
+	if permissionCache.byRole != nil && time.Since(permissionCache.loadedAt) < permissionCacheTTL {
+		return permissionCache.byRole, nil
+	}
+
+	grants, err := dbHelper.GetRolePermissions()
+	if err != nil {
+		return nil, err
+	}
+	byRole := make(map[string]map[string]bool, len(grants))
+	for role, perms := range grants {
+		set := make(map[string]bool, len(perms))
+		for _, perm := range perms {
+			set[perm] = true
+		}
+		byRole[strings.ToLower(role)] = set
+	}
+	permissionCache.byRole, permissionCache.loadedAt = byRole, time.Now()
+	return byRole, nil
+}
+
+// HasPermission reports whether any of the caller's roles grants perm.
+func HasPermission(ctx context.Context, perm string) (bool, error) {
+	roles, _ := ctx.Value(RolesKey).([]string)
+	if len(roles) == 0 {
+		return false, nil
+	}
+	byRole, err := rolePermissions()
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		role = strings.ToLower(role)
+		if role == models.SuperRole || byRole[role][perm] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequirePermission lets a request through only if the caller's roles grant
+// every one of perms.
+func RequirePermission(perms ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, perm := range perms {
+				allowed, err := HasPermission(r.Context(), perm)
+				if err != nil {
+					logrus.Errorf("failed to load permissions: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				if !allowed {
+					logrus.Infof("missing permission %s", perm)
+					http.Error(w, "Forbidden: insufficient permissions", http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
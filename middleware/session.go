@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"net/http"
 	"rms/database/dbHelper"
+	"rms/models"
+	"rms/utils"
 	"strings"
 	"time"
 )
@@ -24,19 +28,58 @@ func SessionValidationMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		session, err := dbHelper.GetSessionByToken(token)
+		session, err := dbHelper.GetSessionByToken(utils.HashToken(token))
 		if err != nil {
 			logrus.Errorf("session not found: %v", err)
 			http.Error(w, "invalid or expired session", http.StatusUnauthorized)
 			return
 		}
 
+		if session.RevokedAt != nil {
+			logrus.Error("session revoked")
+			http.Error(w, "invalid or expired session", http.StatusUnauthorized)
+			return
+		}
+
+		if session.RotatedAt != nil {
+			RevokeReusedSessionFamily(r, session)
+			http.Error(w, "invalid or expired session", http.StatusUnauthorized)
+			return
+		}
+
 		if session.ExpiresAt.Before(time.Now()) {
 			logrus.Error("session expired")
 			http.Error(w, "session expired", http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		ctx := context.WithValue(r.Context(), SessionKey, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RevokeReusedSessionFamily handles a refresh token that was presented after
+// rotation: whoever holds it, the family is compromised, so all of it is revoked.
+func RevokeReusedSessionFamily(r *http.Request, session *models.Session) {
+	logrus.WithFields(logrus.Fields{
+		"user_id":    session.UserID,
+		"family_id":  session.FamilyID,
+		"session_id": session.ID,
+	}).Warn("security event: refresh token reuse detected, revoking token family")
+
+	if err := dbHelper.RevokeSessionFamily(session.FamilyID); err != nil {
+		logrus.Errorf("failed to revoke session family %s: %v", session.FamilyID, err)
+	}
+	if err := utils.DenySessions(session.FamilyID); err != nil {
+		logrus.Errorf("failed to deny session family %s: %v", session.FamilyID, err)
+	}
+	event := models.SecurityEvent{
+		EventType: models.EventRefreshTokenReuse,
+		UserID:    session.UserID,
+		IPAddress: utils.ClientIP(r),
+		Details:   fmt.Sprintf("family %s, session %s", session.FamilyID, session.ID),
+	}
+	if err := dbHelper.RecordSecurityEvent(event); err != nil {
+		logrus.Errorf("failed to record security event: %v", err)
+	}
+}
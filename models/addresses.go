@@ -1,7 +1,58 @@
 package models
 
+import (
+	"github.com/google/uuid"
+	"rms/geocode"
+	"time"
+)
+
+// CreateAddressRequest locates the address by lat and lng, a structured
+// address, or both.
 type CreateAddressRequest struct {
-	Label string  `json:"label"`
-	Lat   float64 `json:"lat"`
-	Lng   float64 `json:"lng"`
+	Label   string          `json:"label"`
+	Lat     float64         `json:"lat"`
+	Lng     float64         `json:"lng"`
+	Address geocode.Address `json:"address"`
+}
+
+// Address is one entry of a user's address book.
+type Address struct {
+	ID              uuid.UUID `db:"id" json:"id"`
+	Label           string    `db:"label" json:"label"`
+	Lat             float64   `db:"lat" json:"lat"`
+	Lng             float64   `db:"lng" json:"lng"`
+	geocode.Address `json:"address"`
+	IsDefault       bool      `db:"is_default" json:"is_default"`
+	CreatedAt       time.Time `db:"created_at" json:"created_at"`
+}
+
+// UpdateAddressRequest changes only the fields that are present.
+type UpdateAddressRequest struct {
+	Label   *string          `json:"label"`
+	Lat     *float64         `json:"lat"`
+	Lng     *float64         `json:"lng"`
+	Address *geocode.Address `json:"address"`
+}
+
+type DistanceMatrixRequest struct {
+	AddressIDs    []string `json:"address_ids"`
+	RestaurantIDs []string `json:"restaurant_ids"`
+}
+
+// DistanceMatrixResponse has one row per address and one column per
+// restaurant, in the order they were requested.
+type DistanceMatrixResponse struct {
+	AddressIDs    []uuid.UUID `json:"address_ids"`
+	RestaurantIDs []uuid.UUID `json:"restaurant_ids"`
+	DistancesKm   [][]float64 `json:"distances_km"`
+}
+
+// DistanceCell is one address/restaurant pair of a distance matrix. The
+// distance is nil when either side was not found.
+type DistanceCell struct {
+	AddressID    uuid.UUID `db:"address_id"`
+	RestaurantID uuid.UUID `db:"restaurant_id"`
+	AddressOK    bool      `db:"address_ok"`
+	RestaurantOK bool      `db:"restaurant_ok"`
+	DistanceKm   *float64  `db:"distance_km"`
 }
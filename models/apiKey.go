@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// APIKeyPrefix starts every API key, which is how a bearer API key is told
+// apart from a JWT.
+const APIKeyPrefix = "rmsk_"
+
+// API key scopes. A key without scopes may use every method its roles allow.
+const (
+	ScopeRead  = "read"  // GET, HEAD and OPTIONS requests
+	ScopeWrite = "write" // everything else
+)
+
+// APIKey acts as UserID with the given roles. The key itself is never stored.
+type APIKey struct {
+	ID         uuid.UUID      `db:"id" json:"id"`
+	Name       string         `db:"name" json:"name"`
+	KeyPrefix  string         `db:"key_prefix" json:"key_prefix"`
+	UserID     uuid.UUID      `db:"user_id" json:"user_id"`
+	Roles      pq.StringArray `db:"roles" json:"roles"`
+	Scopes     pq.StringArray `db:"scopes" json:"scopes"`
+	CreatedBy  uuid.UUID      `db:"created_by" json:"created_by"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time     `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time     `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	UserID    string     `json:"user_id,omitempty"` // defaults to the admin creating the key
+	Roles     []string   `json:"roles"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	Message string `json:"message"`
+	Key     string `json:"key"` // shown once, only its hash is stored
+	APIKey  APIKey `json:"api_key"`
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+)
+
+// DishVariant is a portion such as half or full. Its price replaces the
+// dish's own price.
+type DishVariant struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Price     float64   `db:"price" json:"price"`
+	IsDefault bool      `db:"is_default" json:"is_default"`
+}
+
+// ModifierGroup is a set of add-ons, of which between MinSelect and MaxSelect
+// must be picked.
+type ModifierGroup struct {
+	ID        uuid.UUID        `db:"id" json:"id"`
+	Name      string           `db:"name" json:"name"`
+	MinSelect int              `db:"min_select" json:"min_select"`
+	MaxSelect int              `db:"max_select" json:"max_select"`
+	Options   []ModifierOption `db:"-" json:"options"`
+}
+
+// ModifierOption is one add-on, adding PriceDelta to the price.
+type ModifierOption struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	GroupID    uuid.UUID `db:"group_id" json:"-"`
+	Name       string    `db:"name" json:"name"`
+	PriceDelta float64   `db:"price_delta" json:"price_delta"`
+}
+
+// DishOptions is everything that can be chosen when ordering a dish.
+type DishOptions struct {
+	DishID         uuid.UUID       `json:"dish_id"`
+	Price          float64         `json:"price"`
+	Variants       []DishVariant   `json:"variants"`
+	ModifierGroups []ModifierGroup `json:"modifier_groups"`
+}
+
+// SetDishOptionsRequest replaces a dish's variants and modifier groups, in
+// the order given. Options get new IDs each time.
+type SetDishOptionsRequest struct {
+	Variants       []VariantInput       `json:"variants"`
+	ModifierGroups []ModifierGroupInput `json:"modifier_groups"`
+}
+
+type VariantInput struct {
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	IsDefault bool    `json:"is_default"`
+}
+
+type ModifierGroupInput struct {
+	Name      string                `json:"name"`
+	MinSelect int                   `json:"min_select"`
+	MaxSelect int                   `json:"max_select"`
+	Options   []ModifierOptionInput `json:"options"`
+}
+
+type ModifierOptionInput struct {
+	Name       string  `json:"name"`
+	PriceDelta float64 `json:"price_delta"`
+}
+
+// QuoteRequest is a chosen configuration of a dish. variant_id may be left
+// out when the dish has no variants or has a default one.
+type QuoteRequest struct {
+	VariantID *string  `json:"variant_id"`
+	OptionIDs []string `json:"option_ids"`
+	Quantity  int      `json:"quantity"`
+}
+
+// QuoteLine is a chosen add-on in a quote.
+type QuoteLine struct {
+	ID         uuid.UUID `json:"id"`
+	Group      string    `json:"group"`
+	Name       string    `json:"name"`
+	PriceDelta float64   `json:"price_delta"`
+}
+
+// Quote is the price of a configured dish.
+type Quote struct {
+	DishID    uuid.UUID    `json:"dish_id"`
+	Variant   *DishVariant `json:"variant,omitempty"`
+	Options   []QuoteLine  `json:"options"`
+	UnitPrice float64      `json:"unit_price"`
+	Quantity  int          `json:"quantity"`
+	Total     float64      `json:"total"`
+}
+
+// cents avoids float drift when adding prices up.
+func cents(price float64) int64 {
+	return int64(math.Round(price * 100))
+}
+
+// Quote checks a configuration against the dish's rules and prices it. The
+// error explains what is wrong with the configuration.
+func (o DishOptions) Quote(variantID *uuid.UUID, optionIDs []uuid.UUID, quantity int) (Quote, error) {
+	quote := Quote{DishID: o.DishID, Options: make([]QuoteLine, 0, len(optionIDs)), Quantity: quantity}
+	unit := cents(o.Price)
+
+	switch {
+	case variantID != nil:
+		for i := range o.Variants {
+			if o.Variants[i].ID == *variantID {
+				quote.Variant = &o.Variants[i]
+			}
+		}
+		if quote.Variant == nil {
+			return quote, fmt.Errorf("variant %s is not a variant of this dish", *variantID)
+		}
+	case len(o.Variants) > 0:
+		for i := range o.Variants {
+			if o.Variants[i].IsDefault {
+				quote.Variant = &o.Variants[i]
+			}
+		}
+		if quote.Variant == nil {
+			return quote, fmt.Errorf("variant_id is required for this dish")
+		}
+	}
+	if quote.Variant != nil {
+		unit = cents(quote.Variant.Price)
+	}
+
+	chosen := make(map[uuid.UUID]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		if chosen[id] {
+			return quote, fmt.Errorf("option %s is chosen more than once", id)
+		}
+		chosen[id] = true
+	}
+	for _, group := range o.ModifierGroups {
+		picked := 0
+		for _, option := range group.Options {
+			if !chosen[option.ID] {
+				continue
+			}
+			delete(chosen, option.ID)
+			picked++
+			unit += cents(option.PriceDelta)
+			quote.Options = append(quote.Options, QuoteLine{
+				ID:         option.ID,
+				Group:      group.Name,
+				Name:       option.Name,
+				PriceDelta: option.PriceDelta,
+			})
+		}
+		if picked < group.MinSelect {
+			return quote, fmt.Errorf("%s: choose at least %d", group.Name, group.MinSelect)
+		}
+		if picked > group.MaxSelect {
+			return quote, fmt.Errorf("%s: choose at most %d", group.Name, group.MaxSelect)
+		}
+	}
+	for _, id := range optionIDs {
+		if chosen[id] {
+			return quote, fmt.Errorf("option %s is not an option of this dish", id)
+		}
+	}
+
+	quote.UnitPrice = float64(unit) / 100
+	quote.Total = float64(unit*int64(quantity)) / 100
+	return quote, nil
+}
@@ -0,0 +1,22 @@
+package models
+
+// Page sizes for list endpoints.
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+// ListParams are the paging, sorting and filtering options of a list request.
+type ListParams struct {
+	Limit   int
+	Cursor  string
+	Sort    string // empty for the endpoint's default
+	Desc    bool
+	Filters map[string]string
+}
+
+// Page is one page of a list endpoint. NextCursor is null on the last page.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+}
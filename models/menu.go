@@ -0,0 +1,50 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// MenuSection groups a restaurant's dishes, such as Starters or Drinks.
+type MenuSection struct {
+	ID           uuid.UUID `db:"id" json:"id"`
+	RestaurantID uuid.UUID `db:"restaurant_id" json:"restaurant_id"`
+	Name         string    `db:"name" json:"name"`
+	Description  string    `db:"description" json:"description"`
+	Position     int       `db:"position" json:"position"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+// CreateMenuSectionRequest adds a section at the end of the menu.
+type CreateMenuSectionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// UpdateMenuSectionRequest changes only the fields that are present.
+type UpdateMenuSectionRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// MenuOrderRequest lists every section of a menu, or every dish of a section,
+// in the order they should be shown.
+type MenuOrderRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// MenuSectionDishes is a section with its dishes in display order.
+type MenuSectionDishes struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Dishes      []Dish    `json:"dishes"`
+}
+
+// Menu is a restaurant's active dishes by section. Dishes outside any
+// section follow in OtherDishes.
+type Menu struct {
+	RestaurantID uuid.UUID           `json:"restaurant_id"`
+	Sections     []MenuSectionDishes `json:"sections"`
+	OtherDishes  []Dish              `json:"other_dishes"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// OIDCLoginState is an authorization-code login waiting for its callback.
+type OIDCLoginState struct {
+	State        string    `db:"state"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+// UserIdentity links a subject at an external issuer to a local user.
+type UserIdentity struct {
+	Issuer  string    `db:"issuer"`
+	Subject string    `db:"subject"`
+	UserID  uuid.UUID `db:"user_id"`
+	Email   *string   `db:"email"`
+}
+
+type OIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
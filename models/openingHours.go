@@ -0,0 +1,63 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// OpeningInterval is one opening period on a weekday, as "HH:MM" local times.
+// A closing time earlier than the opening time runs past midnight.
+type OpeningInterval struct {
+	Day    string `json:"day"`
+	Opens  string `json:"opens"`
+	Closes string `json:"closes"`
+}
+
+// SetOpeningHoursRequest replaces a restaurant's weekly hours. An empty week
+// means open around the clock; time_zone is left as it is when missing.
+type SetOpeningHoursRequest struct {
+	TimeZone *string           `json:"time_zone"`
+	Weekly   []OpeningInterval `json:"weekly"`
+}
+
+// Closure is a holiday or ad-hoc closure over [starts_at, ends_at).
+type Closure struct {
+	ID           uuid.UUID  `db:"id" json:"id"`
+	RestaurantID uuid.UUID  `db:"restaurant_id" json:"restaurant_id"`
+	StartsAt     time.Time  `db:"starts_at" json:"starts_at"`
+	EndsAt       time.Time  `db:"ends_at" json:"ends_at"`
+	Reason       string     `db:"reason" json:"reason"`
+	CreatedBy    *uuid.UUID `db:"created_by" json:"created_by,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+}
+
+// CreateClosureRequest closes a restaurant either from starts_at to ends_at,
+// or for whole local days from date through end_date (YYYY-MM-DD; end_date
+// defaults to date).
+type CreateClosureRequest struct {
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	Date     string     `json:"date"`
+	EndDate  string     `json:"end_date"`
+	Reason   string     `json:"reason"`
+}
+
+// PauseRequest flips the paused/busy switch. A pause lasts until it is
+// switched off, until the given time, or for the given number of minutes.
+type PauseRequest struct {
+	Paused     bool       `json:"paused"`
+	Until      *time.Time `json:"until"`
+	ForMinutes int        `json:"for_minutes"`
+}
+
+// OpeningHours is a restaurant's schedule and current status.
+type OpeningHours struct {
+	RestaurantID uuid.UUID         `json:"restaurant_id"`
+	TimeZone     string            `json:"time_zone"`
+	Weekly       []OpeningInterval `json:"weekly"`
+	Closures     []Closure         `json:"closures"`
+	IsPaused     bool              `json:"is_paused"`
+	PausedUntil  *time.Time        `json:"paused_until,omitempty"`
+	IsOpenNow    bool              `json:"is_open_now"`
+	NextOpensAt  *time.Time        `json:"next_opens_at"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Permission names checked by the handlers.
+const (
+	PermRestaurantCreate    = "restaurant:create"
+	PermRestaurantList      = "restaurant:list"
+	PermRestaurantListAll   = "restaurant:list_all"
+	PermRestaurantUpdate    = "restaurant:update"
+	PermRestaurantDelete    = "restaurant:delete"
+	PermRestaurantPurge     = "restaurant:purge"
+	PermDishCreate          = "dish:create"
+	PermDishList            = "dish:list"
+	PermDishListAll         = "dish:list_all"
+	PermDishUpdate          = "dish:update"
+	PermDishDelete          = "dish:delete"
+	PermUserCreate          = "user:create"
+	PermUserList            = "user:list"
+	PermUserListAll         = "user:list_all"
+	PermUserArchive         = "user:archive"
+	PermUserUnlock          = "user:unlock"
+	PermUserSessions        = "user:sessions"
+	PermUserImpersonate     = "user:impersonate"
+	PermSubadminCreate      = "subadmin:create"
+	PermSubadminList        = "subadmin:list"
+	PermAPIKeyManage        = "api_key:manage"
+	PermRoleManage          = "role:manage"
+	PermRestaurantMembers   = "restaurant:members"
+	PermRestaurantManageAll = "restaurant:manage_all"
+)
+
+// SuperRole always holds every permission; its grants cannot be changed.
+const SuperRole = "admin"
+
+type Permission struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+}
+
+type Role struct {
+	ID          uuid.UUID      `db:"id" json:"id"`
+	Name        string         `db:"role_name" json:"name"`
+	IsSystem    bool           `db:"is_system" json:"is_system"`
+	Permissions pq.StringArray `db:"permissions" json:"permissions"`
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type GrantPermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role"`
+}
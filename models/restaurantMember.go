@@ -0,0 +1,44 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Staff roles a user can hold at a single restaurant.
+const (
+	MemberOwner   = "owner"   // everything, including handing over ownership
+	MemberManager = "manager" // restaurant details, menu, and cashier/chef staff
+	MemberChef    = "chef"    // the menu
+	MemberCashier = "cashier" // read-only
+)
+
+// MemberRoles lists the valid staff roles.
+var MemberRoles = []string{MemberOwner, MemberManager, MemberChef, MemberCashier}
+
+// Staff roles allowed to make each kind of change at a restaurant.
+var (
+	RestaurantEditors = []string{MemberOwner, MemberManager}
+	MenuEditors       = []string{MemberOwner, MemberManager, MemberChef}
+)
+
+type RestaurantMember struct {
+	RestaurantID uuid.UUID  `db:"restaurant_id" json:"restaurant_id"`
+	UserID       uuid.UUID  `db:"user_id" json:"user_id"`
+	Username     string     `db:"username" json:"username"`
+	Email        string     `db:"email" json:"email"`
+	Role         string     `db:"role" json:"role"`
+	InvitedBy    *uuid.UUID `db:"invited_by" json:"invited_by,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+}
+
+// AddMemberRequest names the new member by user_id or email.
+type AddMemberRequest struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
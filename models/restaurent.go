@@ -2,41 +2,121 @@ package models
 
 import (
 	"github.com/google/uuid"
+	"rms/geo"
+	"rms/geocode"
+	"time"
 )
 
 type Restaurant struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"restaurantname"`
-	CreatedBy uuid.UUID `json:"created_by"`
-	//CreatedAt time.Time `json:"created_at"`
-	Lat float64 `json:"lat"`
-	Lng float64 `json:"lng"`
+	ID         uuid.UUID  `db:"id" json:"id"`
+	Name       string     `db:"restaurantname" json:"restaurantname"`
+	CreatedBy  uuid.UUID  `db:"created_by" json:"created_by"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	Lat        float64    `db:"lat" json:"lat"`
+	Lng        float64    `db:"lng" json:"lng"`
+	ArchivedAt *time.Time `db:"archived_at" json:"archived_at,omitempty"`
+
+	geocode.Address `json:"address"`
+
+	DeliveryRadiusKm float64      `db:"delivery_radius_km" json:"delivery_radius_km"`
+	DeliveryZone     *geo.Polygon `db:"delivery_zone" json:"delivery_zone,omitempty"`
+
+	TimeZone    string     `db:"time_zone" json:"time_zone"`
+	IsPaused    bool       `db:"is_paused" json:"is_paused"`
+	PausedUntil *time.Time `db:"paused_until" json:"paused_until,omitempty"`
+	IsOpenNow   bool       `db:"is_open_now" json:"is_open_now"`
+	NextOpensAt *time.Time `db:"-" json:"next_opens_at"`
+}
+
+// DeliversTo reports whether a point at distanceKm from the restaurant is
+// within its delivery radius and, if it has one, its delivery zone.
+func (r Restaurant) DeliversTo(lat, lng, distanceKm float64) bool {
+	if distanceKm > r.DeliveryRadiusKm {
+		return false
+	}
+	return r.DeliveryZone == nil || r.DeliveryZone.Contains(lat, lng)
+}
+
+// UpdateRestaurantRequest changes only the fields that are present;
+// clear_delivery_zone drops the zone so only the radius applies.
+type UpdateRestaurantRequest struct {
+	RestaurantName    *string          `json:"restaurant_name"`
+	Lat               *float64         `json:"lat"`
+	Lng               *float64         `json:"lng"`
+	Address           *geocode.Address `json:"address"`
+	DeliveryRadiusKm  *float64         `json:"delivery_radius_km"`
+	DeliveryZone      *geo.Polygon     `json:"delivery_zone"`
+	ClearDeliveryZone bool             `json:"clear_delivery_zone"`
 }
 
+// CreateRestaurantRequest locates the restaurant by lat and lng, a
+// structured address, or both.
 type CreateRestaurantRequest struct {
-	RestaurantName string  `json:"restaurant_name"`
-	Lat            float64 `json:"lat"`
-	Lng            float64 `json:"lng"`
+	RestaurantName   string          `json:"restaurant_name"`
+	Lat              float64         `json:"lat"`
+	Lng              float64         `json:"lng"`
+	Address          geocode.Address `json:"address"`
+	DeliveryRadiusKm float64         `json:"delivery_radius_km"`
+	DeliveryZone     *geo.Polygon    `json:"delivery_zone"`
 }
 
 type CreateDishRequest struct {
 	DishName     string  `json:"dish_name"`
 	RestaurantID string  `json:"restaurant_id"`
 	Price        float64 `json:"price"`
+	Description  string  `json:"description"`
+	SectionID    *string `json:"section_id"`
 }
 
 type Dish struct {
-	ID       uuid.UUID `json:"id"`
-	DishName string    `json:"dishname"`
-	Price    float64   `json:"price"`
+	ID          uuid.UUID  `db:"id" json:"id"`
+	DishName    string     `db:"dishname" json:"dishname"`
+	Description string     `db:"description" json:"description"`
+	Price       float64    `db:"price" json:"price"`
+	SectionID   *uuid.UUID `db:"section_id" json:"-"`
 }
 
 // models/dish.go
 
 type Dishes struct {
-	ID           uuid.UUID `json:"id"`
-	Name         string    `json:"name"`
-	RestaurantID uuid.UUID `json:"restaurant_id"`
-	CreatedBy    uuid.UUID `json:"created_by"`
-	Price        float64   `json:"price"`
+	ID           uuid.UUID  `db:"id" json:"id"`
+	Name         string     `db:"dishname" json:"name"`
+	RestaurantID uuid.UUID  `db:"restaurant_id" json:"restaurant_id"`
+	CreatedBy    uuid.UUID  `db:"created_by" json:"created_by"`
+	Price        float64    `db:"price" json:"price"`
+	Description  string     `db:"description" json:"description"`
+	SectionID    *uuid.UUID `db:"section_id" json:"section_id"`
+	Position     int        `db:"position" json:"position"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	ArchivedAt   *time.Time `db:"archived_at" json:"archived_at,omitempty"`
+}
+
+// UpdateDishRequest changes only the fields that are present; restaurant_id
+// moves the dish to another restaurant, leaving its section unless section_id
+// names one there. clear_section takes the dish out of its section.
+type UpdateDishRequest struct {
+	DishName     *string  `json:"dish_name"`
+	Price        *float64 `json:"price"`
+	RestaurantID *string  `json:"restaurant_id"`
+	Description  *string  `json:"description"`
+	SectionID    *string  `json:"section_id"`
+	ClearSection bool     `json:"clear_section"`
+}
+
+// NearbyRestaurant is a restaurant with its distance from a search point.
+type NearbyRestaurant struct {
+	Restaurant
+	DistanceKm float64 `db:"distance_km" json:"distance_km"`
+}
+
+// Serviceability is the answer to whether a restaurant delivers to one of the
+// caller's addresses.
+type Serviceability struct {
+	RestaurantID     uuid.UUID `json:"restaurant_id"`
+	AddressID        uuid.UUID `json:"address_id"`
+	Serviceable      bool      `json:"serviceable"`
+	DistanceKm       float64   `json:"distance_km"`
+	DeliveryRadiusKm float64   `json:"delivery_radius_km"`
+	HasDeliveryZone  bool      `json:"has_delivery_zone"`
+	InDeliveryZone   *bool     `json:"in_delivery_zone,omitempty"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import "github.com/google/uuid"
+
+// Security event types recorded in security_events
+const (
+	EventRefreshTokenReuse = "refresh_token_reuse"
+	EventLoginLockout      = "login_lockout"
+	EventLoginUnlock       = "login_unlock"
+	EventImpersonation     = "impersonation_started"
+	EventImpersonatedCall  = "impersonated_request"
+)
+
+type SecurityEvent struct {
+	EventType string
+	UserID    uuid.UUID
+	IPAddress string
+	Details   string
+}
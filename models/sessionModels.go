@@ -7,14 +7,66 @@ import (
 )
 
 type Session struct {
-	ID           uuid.UUID `db:"id"`
-	UserID       uuid.UUID `db:"user_id"`
-	RefreshToken string    `db:"refresh_token"`
-	ExpiresAt    time.Time `db:"expires_at"`
-	CreatedAt    time.Time `db:"created_at"`
+	ID           uuid.UUID  `db:"id"`
+	UserID       uuid.UUID  `db:"user_id"`
+	RefreshToken string     `db:"refresh_token"` // SHA-256 hash, never the token itself
+	FamilyID     uuid.UUID  `db:"family_id"`     // shared by every rotation of one sign-in
+	ParentID     *uuid.UUID `db:"parent_id"`     // session this one was rotated from
+	ExpiresAt    time.Time  `db:"expires_at"`
+	CreatedAt    time.Time  `db:"created_at"`
+	RotatedAt    *time.Time `db:"rotated_at"`
+	RevokedAt    *time.Time `db:"revoked_at"`
+	UserAgent    *string    `db:"user_agent"`
+	IPAddress    *string    `db:"ip_address"`
+	LastUsedAt   *time.Time `db:"last_used_at"`
+}
+
+// ClientInfo identifies the device behind a sign-in or refresh.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// SessionInfo is one signed-in device as shown to its user. ID is the token
+// family, so it stays the same across refreshes.
+type SessionInfo struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	LastUsedAt time.Time `db:"last_used_at" json:"last_used_at"`
+	ExpiresAt  time.Time `db:"expires_at" json:"expires_at"`
+	UserAgent  string    `db:"user_agent" json:"user_agent"`
+	IPAddress  string    `db:"ip_address" json:"ip_address"`
+	Current    bool      `db:"-" json:"current"`
 }
 type CustomClaims struct {
-	UserID string   `json:"user_id"`
-	Roles  []string `json:"roles"`
+	UserID    string   `json:"user_id"`
+	Roles     []string `json:"roles"`
+	SessionID string   `json:"sid,omitempty"`
+	Purpose   string   `json:"purpose,omitempty"` // empty for access tokens
+	Act       *Actor   `json:"act,omitempty"`     // set while an admin impersonates UserID
 	jwt.RegisteredClaims
 }
+
+// Actor names who is really behind an impersonation token (RFC 8693 "act").
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// Purposes of single-use tokens tracked in user_tokens
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+	PurposeMFAChallenge      = "mfa_challenge" // not stored, only signed
+)
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ImpersonationResponse struct {
+	Message     string    `json:"message"`
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	UserID      uuid.UUID `json:"user_id"`
+	ActorID     uuid.UUID `json:"actor_id"`
+}
@@ -7,12 +7,13 @@ import (
 
 // User represents a user in the system.
 type User struct {
-	ID        uuid.UUID `db:"id" json:"id"`
-	Username  string    `db:"username" json:"username"`
-	Email     string    `db:"email" json:"email"`
-	Password  string    `db:"password" json:"-"` // omit in JSON response
-	CreatedBy uuid.UUID `db:"created_by" json:"created_by"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ID         uuid.UUID  `db:"id" json:"id"`
+	Username   string     `db:"username" json:"username"`
+	Email      string     `db:"email" json:"email"`
+	Password   string     `db:"password" json:"-"` // omit in JSON response
+	CreatedBy  uuid.UUID  `db:"created_by" json:"created_by"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	VerifiedAt *time.Time `db:"verified_at" json:"verified_at,omitempty"`
 }
 type AddressRequest struct {
 	Label string  `json:"label"`
@@ -49,3 +50,25 @@ type CreateUserRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
@@ -0,0 +1,59 @@
+package revocation
+
+import (
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// MemoryStore keeps revocations in process memory. It is only correct for a
+// single instance and forgets everything on restart.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]time.Time)}
+}
+
+func (s *MemoryStore) Revoke(id string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.entries[id]; !ok || until.After(current) {
+		s.entries[id] = until
+	}
+	s.sweep(time.Now())
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.entries[id]
+	if !ok {
+		return false, nil
+	}
+	if time.Now().After(until) {
+		delete(s.entries, id)
+		return false, nil
+	}
+	return true, nil
+}
+
+// sweep drops expired entries at most once per sweepInterval; callers hold mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	for id, until := range s.entries {
+		if now.After(until) {
+			delete(s.entries, id)
+		}
+	}
+	s.lastSweep = now
+}
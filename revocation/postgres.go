@@ -0,0 +1,34 @@
+package revocation
+
+import (
+	"rms/database"
+	"time"
+)
+
+// PostgresStore keeps revocations in the revoked_tokens table, so they are
+// shared between instances and survive restarts.
+type PostgresStore struct{}
+
+func NewPostgresStore() *PostgresStore {
+	return &PostgresStore{}
+}
+
+func (s *PostgresStore) Revoke(id string, until time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (id, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)
+	`
+	if _, err := database.RMS.Exec(query, id, until); err != nil {
+		return err
+	}
+	_, err := database.RMS.Exec(`DELETE FROM revoked_tokens WHERE expires_at < CURRENT_TIMESTAMP`)
+	return err
+}
+
+func (s *PostgresStore) IsRevoked(id string) (bool, error) {
+	var revoked bool
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE id = $1 AND expires_at > CURRENT_TIMESTAMP)`
+	err := database.RMS.Get(&revoked, query, id)
+	return revoked, err
+}
@@ -0,0 +1,28 @@
+package revocation
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Store remembers revoked token or session IDs until they would have expired anyway.
+type Store interface {
+	Revoke(id string, until time.Time) error
+	IsRevoked(id string) (bool, error)
+}
+
+// Default is the store consulted by AuthMiddleware; main replaces it with FromEnv().
+var Default Store = NewMemoryStore()
+
+// FromEnv builds the store selected by REVOCATION_STORE (postgres or memory).
+func FromEnv() (Store, error) {
+	switch os.Getenv("REVOCATION_STORE") {
+	case "", "postgres":
+		return NewPostgresStore(), nil
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown REVOCATION_STORE %q", os.Getenv("REVOCATION_STORE"))
+	}
+}
@@ -0,0 +1,188 @@
+// Package schedule works out when a restaurant is open from its weekly hours,
+// closures and pause switch. The restaurant_is_open SQL function applies the
+// same rules in queries.
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinutesPerDay is the end of a day; an interval may close at 24:00.
+const MinutesPerDay = 24 * 60
+
+// lookahead is how far past the last closure or pause NextOpen searches, and
+// maxSearch how far it searches at all.
+const (
+	lookahead = 8 * 24 * time.Hour
+	maxSearch = 400 * 24 * time.Hour
+)
+
+// Interval is one opening period, in minutes after local midnight. When
+// Closes is less than Opens the interval runs past midnight into the next day.
+type Interval struct {
+	Weekday time.Weekday
+	Opens   int
+	Closes  int
+}
+
+// Overnight reports whether the interval ends on the following day.
+func (iv Interval) Overnight() bool {
+	return iv.Closes < iv.Opens
+}
+
+// Validate checks the interval's bounds.
+func (iv Interval) Validate() error {
+	if iv.Weekday < time.Sunday || iv.Weekday > time.Saturday {
+		return fmt.Errorf("invalid weekday %d", iv.Weekday)
+	}
+	if iv.Opens < 0 || iv.Opens >= MinutesPerDay || iv.Closes <= 0 || iv.Closes > MinutesPerDay {
+		return fmt.Errorf("%s: times must be between 00:00 and 24:00", iv.Weekday)
+	}
+	if iv.Opens == iv.Closes {
+		return fmt.Errorf("%s: opening and closing times are equal", iv.Weekday)
+	}
+	return nil
+}
+
+// Closure is a holiday or ad-hoc closure over [StartsAt, EndsAt).
+type Closure struct {
+	StartsAt time.Time
+	EndsAt   time.Time
+}
+
+// Schedule is everything that decides whether a restaurant is open. With no
+// intervals it is open around the clock, apart from closures and pauses.
+type Schedule struct {
+	Location    *time.Location
+	Intervals   []Interval
+	Closures    []Closure
+	Paused      bool
+	PausedUntil *time.Time // nil while Paused means until switched back
+}
+
+func (s Schedule) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+// IsPaused reports whether the pause switch is on at t.
+func (s Schedule) IsPaused(t time.Time) bool {
+	return s.Paused && (s.PausedUntil == nil || t.Before(*s.PausedUntil))
+}
+
+// IsOpen reports whether the restaurant is open at t.
+func (s Schedule) IsOpen(t time.Time) bool {
+	if s.IsPaused(t) {
+		return false
+	}
+	for _, c := range s.Closures {
+		if !t.Before(c.StartsAt) && t.Before(c.EndsAt) {
+			return false
+		}
+	}
+	if len(s.Intervals) == 0 {
+		return true
+	}
+
+	local := t.In(s.location())
+	minute := local.Hour()*60 + local.Minute()
+	today := local.Weekday()
+	yesterday := (today + 6) % 7
+	for _, iv := range s.Intervals {
+		if iv.Weekday == today && iv.Opens <= minute && (minute < iv.Closes || iv.Overnight()) {
+			return true
+		}
+		if iv.Weekday == yesterday && iv.Overnight() && minute < iv.Closes {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the first moment from t on when the restaurant is open,
+// or nil if that is not known: it is paused with no end, or has no opening
+// within a week after its last closure.
+func (s Schedule) NextOpen(t time.Time) *time.Time {
+	if s.Paused && s.PausedUntil == nil {
+		return nil
+	}
+
+	// Opening only ever starts at t, when an interval opens, or when a
+	// closure or pause ends, so those are the only moments to check.
+	candidates := []time.Time{t}
+	horizon := t
+	if s.PausedUntil != nil && s.PausedUntil.After(t) {
+		candidates = append(candidates, *s.PausedUntil)
+		horizon = *s.PausedUntil
+	}
+	for _, c := range s.Closures {
+		if c.EndsAt.After(t) {
+			candidates = append(candidates, c.EndsAt)
+			if c.EndsAt.After(horizon) {
+				horizon = c.EndsAt
+			}
+		}
+	}
+	horizon = horizon.Add(lookahead)
+	if limit := t.Add(maxSearch); horizon.After(limit) {
+		horizon = limit
+	}
+
+	loc := s.location()
+	local := t.In(loc)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc); !day.After(horizon); day = day.AddDate(0, 0, 1) {
+		for _, iv := range s.Intervals {
+			if iv.Weekday == day.Weekday() {
+				opens := time.Date(day.Year(), day.Month(), day.Day(), 0, iv.Opens, 0, 0, loc)
+				if !opens.Before(t) {
+					candidates = append(candidates, opens)
+				}
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	for _, c := range candidates {
+		if c.After(horizon) {
+			break
+		}
+		if s.IsOpen(c) {
+			return &c
+		}
+	}
+	return nil
+}
+
+// ParseClock parses "HH:MM" into minutes after midnight; "24:00" is allowed.
+func ParseClock(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	h, errH := strconv.Atoi(hours)
+	m, errM := strconv.Atoi(minutes)
+	if !ok || errH != nil || errM != nil || len(minutes) != 2 || h < 0 || m < 0 || m > 59 || h*60+m > MinutesPerDay {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", s)
+	}
+	return h*60 + m, nil
+}
+
+// FormatClock formats minutes after midnight as "HH:MM".
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// ParseWeekday accepts an English day name such as "monday" or "Mon".
+func ParseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || (len(s) >= 3 && strings.HasPrefix(name, s)) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day %q", s)
+}
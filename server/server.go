@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"rms/handlers"
 	"rms/middleware"
+	"rms/models"
 )
 
 func SetupRoutes() http.Handler {
@@ -23,8 +24,16 @@ func SetupRoutes() http.Handler {
 	}).Methods("GET")
 
 	// Public Routes
-	//r.HandleFunc("/signup", handlers.RegisterHandler).Methods("POST")
+	r.HandleFunc("/.well-known/jwks.json", handlers.JWKSHandler).Methods("GET")
+	r.HandleFunc("/signup", handlers.RegisterHandler).Methods("POST")
 	r.HandleFunc("/signin", handlers.LoginHandler).Methods("POST")
+	r.HandleFunc("/signin/2fa", handlers.LoginMFAHandler).Methods("POST")
+	r.HandleFunc("/oidc/login", handlers.OIDCLoginHandler).Methods("GET")
+	r.HandleFunc("/oidc/callback", handlers.OIDCCallbackHandler).Methods("GET")
+	r.HandleFunc("/verify-email", handlers.VerifyEmailHandler).Methods("POST")
+	r.HandleFunc("/verify-email/resend", handlers.ResendVerificationHandler).Methods("POST")
+	r.HandleFunc("/password/forgot", handlers.ForgotPasswordHandler).Methods("POST")
+	r.HandleFunc("/password/reset", handlers.ResetPasswordHandler).Methods("POST")
 
 	// Session protected routes
 	session := r.PathPrefix("/session").Subrouter()
@@ -36,27 +45,91 @@ func SetupRoutes() http.Handler {
 	openRoutes := r.PathPrefix("/").Subrouter()
 	openRoutes.Use(middleware.AuthMiddleware)
 	openRoutes.HandleFunc("/restaurants", handlers.GetAllRestaurants).Methods("GET")
+	openRoutes.HandleFunc("/restaurants/nearby", handlers.GetNearbyRestaurants).Methods("GET")
 	openRoutes.HandleFunc("/restaurants/{restaurant_id}/dishes", handlers.GetDishesByRestaurant).Methods("GET")
+	openRoutes.HandleFunc("/restaurants/{restaurant_id}/serviceable", handlers.GetRestaurantServiceability).Methods("GET")
+	openRoutes.HandleFunc("/restaurants/{restaurant_id}/hours", handlers.GetOpeningHours).Methods("GET")
+	openRoutes.HandleFunc("/dishes/{dish_id}/options", handlers.GetDishOptions).Methods("GET")
+	openRoutes.HandleFunc("/dishes/{dish_id}/quote", handlers.QuoteDish).Methods("POST")
 	openRoutes.HandleFunc("/user-address", handlers.AddUserAddress).Methods("POST")
 	openRoutes.HandleFunc("/distance", handlers.GetDistanceFromAddress).Methods("GET")
+	openRoutes.HandleFunc("/distance/matrix", handlers.GetDistanceMatrix).Methods("POST")
 
-	//only for admin
+	// account-level operations, for signed-in people only
+	me := openRoutes.PathPrefix("/me").Subrouter()
+	me.Use(middleware.RequireUserSession)
+	me.HandleFunc("/password", handlers.ChangePasswordHandler).Methods("PUT")
+	me.HandleFunc("/2fa/enroll", handlers.EnrollMFA).Methods("POST")
+	me.HandleFunc("/2fa/confirm", handlers.ConfirmMFA).Methods("POST")
+	me.HandleFunc("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes).Methods("POST")
+	me.HandleFunc("/2fa", handlers.DisableMFA).Methods("DELETE")
+	me.HandleFunc("/sessions", handlers.ListMySessions).Methods("GET")
+	me.HandleFunc("/sessions", handlers.RevokeAllMySessions).Methods("DELETE")
+	me.HandleFunc("/sessions/{session_id}", handlers.RevokeMySession).Methods("DELETE")
+	me.HandleFunc("/addresses", handlers.ListMyAddresses).Methods("GET")
+	me.HandleFunc("/addresses", handlers.AddUserAddress).Methods("POST")
+	me.HandleFunc("/addresses/{address_id}", handlers.UpdateMyAddress).Methods("PATCH")
+	me.HandleFunc("/addresses/{address_id}", handlers.ArchiveMyAddress).Methods("DELETE")
+	me.HandleFunc("/addresses/{address_id}/default", handlers.SetMyDefaultAddress).Methods("PUT")
+
+	//admin areas, each route gated by its own permission
 	adminOnly := r.PathPrefix("/admin-only").Subrouter()
 	adminOnly.Use(middleware.AuthMiddleware)
-	adminOnly.Use(middleware.RequireRolesMiddleware("admin"))
-	adminOnly.HandleFunc("/subadmins", handlers.CreateSubadmin).Methods("POST")
-	adminOnly.HandleFunc("/subadmins", handlers.ListSubadmins).Methods("GET")
+	adminOnly.Use(middleware.RequireMFAEnrollment)
+	adminOnly.Handle("/subadmins", withPermission(models.PermSubadminCreate, handlers.CreateSubadmin)).Methods("POST")
+	adminOnly.Handle("/subadmins", withPermission(models.PermSubadminList, handlers.ListSubadmins)).Methods("GET")
+	adminOnly.Handle("/api-keys", withPermission(models.PermAPIKeyManage, handlers.CreateAPIKey)).Methods("POST")
+	adminOnly.Handle("/api-keys", withPermission(models.PermAPIKeyManage, handlers.ListAPIKeys)).Methods("GET")
+	adminOnly.Handle("/api-keys/{key_id}", withPermission(models.PermAPIKeyManage, handlers.RevokeAPIKey)).Methods("DELETE")
+	adminOnly.Handle("/permissions", withPermission(models.PermRoleManage, handlers.ListPermissions)).Methods("GET")
+	adminOnly.Handle("/roles", withPermission(models.PermRoleManage, handlers.ListRoles)).Methods("GET")
+	adminOnly.Handle("/roles", withPermission(models.PermRoleManage, handlers.CreateRole)).Methods("POST")
+	adminOnly.Handle("/roles/{role}", withPermission(models.PermRoleManage, handlers.DeleteRole)).Methods("DELETE")
+	adminOnly.Handle("/roles/{role}/permissions", withPermission(models.PermRoleManage, handlers.GrantRolePermissions)).Methods("POST")
+	adminOnly.Handle("/roles/{role}/permissions/{permission}", withPermission(models.PermRoleManage, handlers.RevokeRolePermission)).Methods("DELETE")
+	adminOnly.Handle("/restaurants/{restaurant_id}", withPermission(models.PermRestaurantPurge, handlers.PurgeRestaurant)).Methods("DELETE")
+	adminOnly.Handle("/users/{user_id}", withPermission(models.PermUserArchive, handlers.ArchiveUser)).Methods("DELETE")
+	adminOnly.Handle("/users/{user_id}/roles", withPermission(models.PermRoleManage, handlers.AssignUserRole)).Methods("POST")
+	adminOnly.Handle("/users/{user_id}/roles/{role}", withPermission(models.PermRoleManage, handlers.RemoveUserRole)).Methods("DELETE")
+	adminOnly.Handle("/users/{user_id}/impersonate", withPermission(models.PermUserImpersonate, handlers.ImpersonateUser)).Methods("POST")
+	adminOnly.Handle("/users/{user_id}/unlock", withPermission(models.PermUserUnlock, handlers.UnlockUser)).Methods("POST")
+	adminOnly.Handle("/users/{user_id}/sessions", withPermission(models.PermUserSessions, handlers.ListUserSessions)).Methods("GET")
+	adminOnly.Handle("/users/{user_id}/sessions", withPermission(models.PermUserSessions, handlers.RevokeAllUserSessions)).Methods("DELETE")
+	adminOnly.Handle("/users/{user_id}/sessions/{session_id}", withPermission(models.PermUserSessions, handlers.RevokeUserSession)).Methods("DELETE")
 
-	//for admin or subadmin
 	adminSubadmin := r.PathPrefix("/admin-subadmin").Subrouter()
 	adminSubadmin.Use(middleware.AuthMiddleware)
-	adminSubadmin.Use(middleware.RequireRolesMiddleware("admin", "subadmin"))
-	adminSubadmin.HandleFunc("/users", handlers.CreateUserByAdminOrSubadmin).Methods("POST")
-	adminSubadmin.HandleFunc("/restaurants", handlers.CreateRestaurant).Methods("POST")
-	adminSubadmin.HandleFunc("/dishes", handlers.CreateDish).Methods("POST")
-	adminSubadmin.HandleFunc("/users", handlers.ListUsers).Methods("GET")
-	adminSubadmin.HandleFunc("/restaurants", handlers.ListRestaurants).Methods("GET")
-	adminSubadmin.HandleFunc("/dishes", handlers.ListDishes).Methods("GET")
+	adminSubadmin.Use(middleware.RequireMFAEnrollment)
+	adminSubadmin.Handle("/users", withPermission(models.PermUserCreate, handlers.CreateUserByAdminOrSubadmin)).Methods("POST")
+	adminSubadmin.Handle("/restaurants", withPermission(models.PermRestaurantCreate, handlers.CreateRestaurant)).Methods("POST")
+	adminSubadmin.Handle("/dishes", withPermission(models.PermDishCreate, handlers.CreateDish)).Methods("POST")
+	adminSubadmin.Handle("/users", withPermission(models.PermUserList, handlers.ListUsers)).Methods("GET")
+	adminSubadmin.Handle("/restaurants", withPermission(models.PermRestaurantList, handlers.ListRestaurants)).Methods("GET")
+	adminSubadmin.Handle("/dishes", withPermission(models.PermDishList, handlers.ListDishes)).Methods("GET")
+	adminSubadmin.Handle("/dishes/{dish_id}", withPermission(models.PermDishUpdate, handlers.UpdateDish)).Methods("PATCH")
+	adminSubadmin.Handle("/dishes/{dish_id}", withPermission(models.PermDishDelete, handlers.ArchiveDish)).Methods("DELETE")
+	adminSubadmin.Handle("/dishes/{dish_id}/restore", withPermission(models.PermDishDelete, handlers.RestoreDish)).Methods("POST")
+	adminSubadmin.Handle("/dishes/{dish_id}/options", withPermission(models.PermDishUpdate, handlers.SetDishOptions)).Methods("PUT")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}", withPermission(models.PermRestaurantUpdate, handlers.UpdateRestaurant)).Methods("PATCH")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}", withPermission(models.PermRestaurantDelete, handlers.ArchiveRestaurant)).Methods("DELETE")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/restore", withPermission(models.PermRestaurantDelete, handlers.RestoreRestaurant)).Methods("POST")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/members", withPermission(models.PermRestaurantMembers, handlers.ListRestaurantMembers)).Methods("GET")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/members", withPermission(models.PermRestaurantMembers, handlers.AddRestaurantMember)).Methods("POST")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/members/{user_id}", withPermission(models.PermRestaurantMembers, handlers.UpdateRestaurantMember)).Methods("PATCH")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/members/{user_id}", withPermission(models.PermRestaurantMembers, handlers.RemoveRestaurantMember)).Methods("DELETE")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/sections", withPermission(models.PermDishCreate, handlers.CreateMenuSection)).Methods("POST")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/sections/order", withPermission(models.PermDishUpdate, handlers.ReorderMenuSections)).Methods("PUT")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/sections/{section_id}", withPermission(models.PermDishUpdate, handlers.UpdateMenuSection)).Methods("PATCH")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/sections/{section_id}", withPermission(models.PermDishDelete, handlers.DeleteMenuSection)).Methods("DELETE")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/sections/{section_id}/dishes/order", withPermission(models.PermDishUpdate, handlers.ReorderSectionDishes)).Methods("PUT")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/hours", withPermission(models.PermRestaurantUpdate, handlers.SetOpeningHours)).Methods("PUT")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/closures", withPermission(models.PermRestaurantUpdate, handlers.AddRestaurantClosure)).Methods("POST")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/closures/{closure_id}", withPermission(models.PermRestaurantUpdate, handlers.RemoveRestaurantClosure)).Methods("DELETE")
+	adminSubadmin.Handle("/restaurants/{restaurant_id}/pause", withPermission(models.PermRestaurantUpdate, handlers.SetRestaurantPause)).Methods("PUT")
 
 	return r
 }
+
+func withPermission(perm string, h http.HandlerFunc) http.Handler {
+	return middleware.RequirePermission(perm)(h)
+}
@@ -2,35 +2,135 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"os"
 	"rms/database"
+	"rms/database/dbHelper"
 	"rms/models"
+	"rms/revocation"
+	"strconv"
 	"time"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+// AccessTokenTTL is how long an access token lives, and so how long a
+// revocation has to be remembered.
+const AccessTokenTTL = 15 * time.Minute
 
-func GenerateJWT(userID string, roles []string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"roles":   roles,
-		"exp":     time.Now().Add(time.Minute * 15).Unix(),
+// GenerateJWT issues an access token for one session. Each token gets its own
+// jti, and sid ties it to the session so revoking the session revokes it too.
+func GenerateJWT(userID string, sessionID uuid.UUID, roles []string) (string, error) {
+	claims := models.CustomClaims{
+		UserID:    userID,
+		Roles:     roles,
+		SessionID: sessionID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
+		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	kr, err := currentKeyRing()
+	if err != nil {
+		return "", err
+	}
+	return kr.sign(claims)
+}
+
+// GenerateImpersonationJWT issues an access token that acts as userID on behalf
+// of actorID. It has no refresh token and shares the actor's session, so the
+// admin signing out ends it too.
+func GenerateImpersonationJWT(userID, actorID, actorSessionID uuid.UUID, roles []string, ttl time.Duration) (string, time.Time, error) {
+	// Revocations are only remembered for AccessTokenTTL
+	if ttl <= 0 || ttl > AccessTokenTTL {
+		ttl = AccessTokenTTL
+	}
+	expiresAt := time.Now().Add(ttl)
+	claims := models.CustomClaims{
+		UserID:    userID.String(),
+		Roles:     roles,
+		SessionID: actorSessionID.String(),
+		Act:       &models.Actor{Subject: actorID.String()},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	kr, err := currentKeyRing()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	token, err := kr.sign(claims)
+	return token, expiresAt, err
 }
 
 func ParseJWT(tokenStr string) (*models.CustomClaims, error) {
+	kr, err := currentKeyRing()
+	if err != nil {
+		return nil, err
+	}
 	claims := &models.CustomClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-	if err != nil || !token.Valid {
+	token, err := kr.parse(tokenStr, claims)
+	if err != nil || !token.Valid || claims.Purpose != "" || claims.ID == "" {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// IsAccessTokenRevoked reports whether the token or its session has been revoked.
+func IsAccessTokenRevoked(claims *models.CustomClaims) (bool, error) {
+	revoked, err := revocation.Default.IsRevoked(claims.ID)
+	if err != nil || revoked || claims.SessionID == "" {
+		return revoked, err
+	}
+	return revocation.Default.IsRevoked(claims.SessionID)
+}
+
+// DenySessions makes access tokens already issued for these sessions unusable
+// immediately, instead of when they expire.
+func DenySessions(sessionIDs ...uuid.UUID) error {
+	until := time.Now().Add(AccessTokenTTL)
+	for _, id := range sessionIDs {
+		if err := revocation.Default.Revoke(id.String(), until); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GeneratePurposeToken signs a short-lived token for a single purpose such as
+// email verification. id becomes the jti so the caller can enforce single use.
+func GeneratePurposeToken(id, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	claims := models.CustomClaims{
+		UserID:  userID.String(),
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id.String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	}
+	kr, err := currentKeyRing()
+	if err != nil {
+		return "", err
+	}
+	return kr.sign(claims)
+}
+
+// ParsePurposeToken validates a token from GeneratePurposeToken and checks its purpose.
+func ParsePurposeToken(tokenStr, purpose string) (*models.CustomClaims, error) {
+	kr, err := currentKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	claims := &models.CustomClaims{}
+
+	token, err := kr.parse(tokenStr, claims)
+	if err != nil || !token.Valid || claims.Purpose != purpose {
 		return nil, errors.New("invalid token")
 	}
 
@@ -46,35 +146,97 @@ func generateSecureToken(n int) (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-// CreateRefreshToken creates and saves a refresh token in the DB
-func CreateRefreshToken(userID uuid.UUID) (string, error) {
-	token, err := generateSecureToken(32)
+// GenerateAPIKey returns a new API key and the short prefix shown to admins.
+func GenerateAPIKey() (string, string, error) {
+	secret, err := generateSecureToken(32)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
+	key := models.APIKeyPrefix + secret
+	return key, key[:len(models.APIKeyPrefix)+8], nil
+}
+
+// ErrRefreshTokenReused means a refresh token was presented after it had already been rotated.
+var ErrRefreshTokenReused = errors.New("refresh token already used")
+
+// HashToken returns the hex SHA-256 digest under which opaque tokens are stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
 
+func refreshTokenExpiry() time.Time {
 	expiryDays := 7
 	if envDays := os.Getenv("REFRESH_TOKEN_EXPIRY_DAYS"); envDays != "" {
-		if parsed, err := time.ParseDuration(envDays + "d"); err == nil {
-			expiryDays = int(parsed.Hours() / 24)
+		if parsed, err := strconv.Atoi(envDays); err == nil && parsed > 0 {
+			expiryDays = parsed
 		}
 	}
+	return time.Now().Add(time.Hour * 24 * time.Duration(expiryDays))
+}
+
+// CreateRefreshToken creates and saves a refresh token in the DB, starting a new
+// token family. The family ID doubles as the session ID of the access tokens.
+func CreateRefreshToken(userID uuid.UUID, client models.ClientInfo) (string, uuid.UUID, error) {
+	token, err := generateSecureToken(32)
+	if err != nil {
+		return "", uuid.Nil, err
+	}
 
+	id := uuid.New()
+	now := time.Now()
 	session := models.Session{
-		ID:           uuid.New(),
+		ID:           id,
 		UserID:       userID,
-		RefreshToken: token,
-		ExpiresAt:    time.Now().Add(time.Hour * 24 * time.Duration(expiryDays)),
-		CreatedAt:    time.Now(),
+		RefreshToken: HashToken(token),
+		FamilyID:     id,
+		ExpiresAt:    refreshTokenExpiry(),
+		CreatedAt:    now,
+		UserAgent:    &client.UserAgent,
+		IPAddress:    &client.IPAddress,
+		LastUsedAt:   &now,
 	}
 	//start session
-	query := `
-		INSERT INTO sessions (id, user_id, refresh_token, expires_at, created_at)
-		VALUES (:id, :user_id, :refresh_token, :expires_at, :created_at)
-	`
+	if err := dbHelper.InsertSession(database.RMS, &session); err != nil {
+		return "", uuid.Nil, errors.New("could not save refresh token")
+	}
 
-	if _, err := database.RMS.NamedExec(query, &session); err != nil {
-		return "", errors.New("could not save refresh token")
+	return token, id, nil
+}
+
+// RotateRefreshToken retires parent and issues its successor in the same family.
+// It returns ErrRefreshTokenReused if parent was already rotated or revoked.
+func RotateRefreshToken(parent *models.Session, client models.ClientInfo) (string, error) {
+	token, err := generateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:           uuid.New(),
+		UserID:       parent.UserID,
+		RefreshToken: HashToken(token),
+		FamilyID:     parent.FamilyID,
+		ParentID:     &parent.ID,
+		ExpiresAt:    refreshTokenExpiry(),
+		CreatedAt:    now,
+		UserAgent:    &client.UserAgent,
+		IPAddress:    &client.IPAddress,
+		LastUsedAt:   &now,
+	}
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		rotated, err := dbHelper.MarkSessionRotated(tx, parent.ID)
+		if err != nil {
+			return err
+		}
+		if !rotated {
+			return ErrRefreshTokenReused
+		}
+		return dbHelper.InsertSession(tx, &session)
+	})
+	if err != nil {
+		return "", err
 	}
 
 	return token, nil
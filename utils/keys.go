@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// signingKey is one entry of a KeyRing. Retired keys have no private half and
+// are only used to verify tokens issued before a rotation.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// KeyRing holds the key new tokens are signed with plus every key, current or
+// retired, that tokens may still be verified against.
+type KeyRing struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var keyRing *KeyRing
+
+// SetKeyRing installs the key ring used to sign and verify tokens. main calls
+// it once at startup, after the environment has been loaded.
+func SetKeyRing(kr *KeyRing) {
+	keyRing = kr
+}
+
+func currentKeyRing() (*KeyRing, error) {
+	if keyRing == nil {
+		return nil, errors.New("signing keys not loaded")
+	}
+	return keyRing, nil
+}
+
+// PublicJWKS returns the published verification keys.
+func PublicJWKS() (JWKS, error) {
+	kr, err := currentKeyRing()
+	if err != nil {
+		return JWKS{}, err
+	}
+	return kr.JWKS(), nil
+}
+
+// KeyRingFromEnv loads signing keys from JWT_KEYS_DIR (one PEM file per key,
+// named <kid>.pem) and/or a single PEM in JWT_SIGNING_KEY. JWT_ACTIVE_KID picks
+// the signing key; otherwise the highest kid wins, so date-prefixed names
+// rotate naturally. Without any keys an ephemeral key is generated.
+func KeyRingFromEnv() (*KeyRing, error) {
+	kr := &KeyRing{keys: make(map[string]*signingKey)}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+			if err := kr.add(kid, data); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+
+	if inline := os.Getenv("JWT_SIGNING_KEY"); inline != "" {
+		kid := os.Getenv("JWT_ACTIVE_KID")
+		if kid == "" {
+			kid = "env"
+		}
+		if err := kr.add(kid, []byte(inline)); err != nil {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY: %w", err)
+		}
+	}
+
+	if len(kr.keys) == 0 {
+		logrus.Warn("no JWT signing keys configured, using an ephemeral key; tokens will not survive a restart")
+		return NewEphemeralKeyRing()
+	}
+
+	if err := kr.activate(os.Getenv("JWT_ACTIVE_KID")); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// NewEphemeralKeyRing returns a key ring with a freshly generated Ed25519 key.
+func NewEphemeralKeyRing() (*KeyRing, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(public)
+	key := &signingKey{
+		kid:     "ephemeral-" + hex.EncodeToString(sum[:4]),
+		method:  jwt.SigningMethodEdDSA,
+		private: private,
+		public:  public,
+	}
+	return &KeyRing{active: key, keys: map[string]*signingKey{key.kid: key}}, nil
+}
+
+func (kr *KeyRing) add(kid string, data []byte) error {
+	if _, exists := kr.keys[kid]; exists {
+		return fmt.Errorf("duplicate kid %q", kid)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	key := &signingKey{kid: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+	kr.keys[kid] = key
+	return nil
+}
+
+func (kr *KeyRing) activate(kid string) error {
+	if kid == "" {
+		kids := make([]string, 0, len(kr.keys))
+		for id, key := range kr.keys {
+			if key.private != nil {
+				kids = append(kids, id)
+			}
+		}
+		if len(kids) == 0 {
+			return errors.New("no private signing key configured")
+		}
+		sort.Strings(kids)
+		kid = kids[len(kids)-1]
+	}
+
+	key, ok := kr.keys[kid]
+	if !ok || key.private == nil {
+		return fmt.Errorf("no private key with kid %q", kid)
+	}
+	kr.active = key
+	logrus.Infof("signing tokens with key %q (%s), %d key(s) accepted", kid, key.method.Alg(), len(kr.keys))
+	return nil
+}
+
+func (kr *KeyRing) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(kr.active.method, claims)
+	token.Header["kid"] = kr.active.kid
+	return token.SignedString(kr.active.private)
+}
+
+// keyFunc finds the verification key named by the token's kid and makes sure
+// the token uses that key's algorithm.
+func (kr *KeyRing) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.public, nil
+}
+
+func (kr *KeyRing) parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, kr.keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+}
+
+// JWKS renders every accepted key, so tokens signed before a rotation keep
+// verifying elsewhere until they expire.
+func (kr *KeyRing) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range kr.keys {
+		jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"rms/models"
+	"strings"
+)
+
+// ClientIP returns the caller's IP. X-Forwarded-For is only honoured when
+// TRUST_PROXY_HEADERS is set, since clients can forge it otherwise.
+func ClientIP(r *http.Request) string {
+	if EnvBool("TRUST_PROXY_HEADERS") {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ClientInfoFromRequest(r *http.Request) models.ClientInfo {
+	return models.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: ClientIP(r),
+	}
+}